@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A chanTracer records the channel operations of an example, so they can be drawn as a sequence diagram.
+/* Tracing is opt-in. Examples mark their channel operations with the context's trace helpers,
+which do nothing unless the run was started with --chan-trace:
+
+	ec.traceSend("msg", v) // just before msg <- v
+	msg <- v
+
+	v := <-msg
+	ec.traceRecv("msg", v) // just after the receive
+
+Recording sends before they happen and receives after they complete keeps every send ahead
+of the receive it is paired with. Each event carries the goroutine it ran on, the channel's
+name, the value and the time on the example's clock. The log exports as JSON and as
+Mermaid or PlantUML sequence diagrams, with goroutines and channels as participants:
+
+	go-concepts run --chan-trace channels.mmd channels
+	go-concepts run --chan-trace select.puml --fake-clock select
+	go-concepts run --chan-trace events.json channels select
+*/
+type chanTracer struct {
+	mu     sync.Mutex
+	start  time.Time // time of the first event
+	events []chanEvent
+}
+
+type chanEvent struct {
+	Seq       int           `json:"seq"`
+	Elapsed   time.Duration `json:"elapsed_ns"` // since the first event
+	Goroutine int           `json:"goroutine"`
+	Op        string        `json:"op"` // send, recv, close or select
+	Channel   string        `json:"channel"`
+	Value     string        `json:"value,omitempty"`
+}
+
+// record adds one event that happened at now. It is a no-op on a nil tracer.
+func (t *chanTracer) record(now time.Time, op, channel string, value interface{}) {
+	if t == nil {
+		return
+	}
+	g := currentGoroutineID()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.events) == 0 {
+		t.start = now
+	}
+	e := chanEvent{
+		Seq:       len(t.events) + 1,
+		Elapsed:   now.Sub(t.start),
+		Goroutine: g,
+		Op:        op,
+		Channel:   channel,
+	}
+	if value != nil {
+		e.Value = fmt.Sprint(value)
+	}
+	t.events = append(t.events, e)
+}
+
+// Events returns a copy of the events recorded so far.
+func (t *chanTracer) Events() []chanEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]chanEvent(nil), t.events...)
+}
+
+func (ec *exampleContext) traceSend(channel string, v interface{}) { ec.traceOp("send", channel, v) }
+func (ec *exampleContext) traceRecv(channel string, v interface{}) { ec.traceOp("recv", channel, v) }
+func (ec *exampleContext) traceClose(channel string)               { ec.traceOp("close", channel, nil) }
+
+// traceSelect records the case a select chose and the value it received, if any.
+func (ec *exampleContext) traceSelect(channel string, v interface{}) {
+	ec.traceOp("select", channel, v)
+}
+
+func (ec *exampleContext) traceOp(op, channel string, v interface{}) {
+	if ec.trace != nil {
+		ec.trace.record(ec.clock.Now(), op, channel, v)
+	}
+}
+
+// participants names goroutines G1, G2, ... in order of first appearance, and lists
+// them and the channels in that order.
+func participants(events []chanEvent) (order []string, goroutine map[int]string) {
+	goroutine = map[int]string{}
+	seen := map[string]bool{}
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			order = append(order, p)
+		}
+	}
+	for _, e := range events {
+		if _, ok := goroutine[e.Goroutine]; !ok {
+			goroutine[e.Goroutine] = fmt.Sprintf("G%d", len(goroutine)+1)
+		}
+		add(goroutine[e.Goroutine])
+		add(e.Channel)
+	}
+	return order, goroutine
+}
+
+// arrow describes an event as a message between a goroutine and a channel.
+func arrow(e chanEvent, g string) (from, to, label string) {
+	switch e.Op {
+	case "send":
+		return g, e.Channel, "send " + e.Value
+	case "close":
+		return g, e.Channel, "close"
+	case "select":
+		return e.Channel, g, strings.TrimSpace("select " + e.Value)
+	default:
+		return e.Channel, g, "recv " + e.Value
+	}
+}
+
+// writeMermaid writes the events as a Mermaid sequence diagram.
+func writeMermaid(w io.Writer, events []chanEvent) {
+	order, goroutine := participants(events)
+	fmt.Fprintln(w, "sequenceDiagram")
+	for _, p := range order {
+		kind := "participant"
+		if isGoroutineName(p, goroutine) {
+			kind = "actor"
+		}
+		fmt.Fprintf(w, "    %s %s\n", kind, p)
+	}
+	for _, e := range events {
+		from, to, label := arrow(e, goroutine[e.Goroutine])
+		fmt.Fprintf(w, "    %s->>%s: %s (%v)\n", from, to, mermaidEscape(label), e.Elapsed.Round(time.Millisecond))
+	}
+}
+
+// writePlantUML writes the events as a PlantUML sequence diagram.
+func writePlantUML(w io.Writer, events []chanEvent) {
+	order, goroutine := participants(events)
+	fmt.Fprintln(w, "@startuml")
+	for _, p := range order {
+		kind := "queue"
+		if isGoroutineName(p, goroutine) {
+			kind = "participant"
+		}
+		fmt.Fprintf(w, "%s %s\n", kind, p)
+	}
+	for _, e := range events {
+		from, to, label := arrow(e, goroutine[e.Goroutine])
+		fmt.Fprintf(w, "%s -> %s : %s (%v)\n", from, to, label, e.Elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w, "@enduml")
+}
+
+// writeTraceJSON writes the events as a JSON array.
+func writeTraceJSON(w io.Writer, events []chanEvent) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if events == nil {
+		events = []chanEvent{}
+	}
+	return enc.Encode(events)
+}
+
+func isGoroutineName(p string, goroutine map[int]string) bool {
+	for _, name := range goroutine {
+		if name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// mermaidEscape replaces the characters Mermaid treats as syntax in message text.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(";", "#59;", "#", "#35;").Replace(s)
+}
+
+// writeChanTrace writes the events in the format the file name's extension asks for.
+func writeChanTrace(w io.Writer, name string, events []chanEvent) error {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return writeTraceJSON(w, events)
+	case ".mmd", ".mermaid":
+		writeMermaid(w, events)
+	case ".puml", ".plantuml":
+		writePlantUML(w, events)
+	default:
+		return fmt.Errorf("unknown trace format %q: use .json, .mmd or .puml", filepath.Ext(name))
+	}
+	return nil
+}
+
+// saveChanTrace writes the events to the file name.
+func saveChanTrace(name string, events []chanEvent) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := writeChanTrace(f, name, events); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
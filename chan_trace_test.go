@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tracedRun(name string) []chanEvent {
+	ex, _ := lookupExample(name)
+	ec := newExampleContext(io.Discard)
+	ec.trace = &chanTracer{}
+	fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+	ec.clock = fc
+	fc.run(func() { ex.run(ec) })
+	return ec.trace.Events()
+}
+
+// TestChanTraceSendsBeforeReceives checks that every value received was sent
+// earlier on the same channel, by another goroutine when the channel is unbuffered.
+func TestChanTraceSendsBeforeReceives(t *testing.T) {
+	events := tracedRun("channels")
+	if len(events) == 0 {
+		t.Fatal("no events recorded")
+	}
+	sent := map[string][]chanEvent{}
+	for _, e := range events {
+		switch e.Op {
+		case "send":
+			sent[e.Channel] = append(sent[e.Channel], e)
+		case "recv", "select":
+			if e.Channel == "done" {
+				continue
+			}
+			found := false
+			for i, s := range sent[e.Channel] {
+				if s.Value == e.Value {
+					sent[e.Channel] = append(sent[e.Channel][:i], sent[e.Channel][i+1:]...)
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("event %d received %q on %s before it was sent", e.Seq, e.Value, e.Channel)
+			}
+		}
+	}
+	for ch, pending := range sent {
+		if len(pending) > 0 {
+			t.Errorf("%d value(s) sent on %s were never received", len(pending), ch)
+		}
+	}
+}
+
+func TestChanTraceExports(t *testing.T) {
+	events := tracedRun("select")
+
+	var mermaid, puml, js bytes.Buffer
+	for name, buf := range map[string]*bytes.Buffer{"a.mmd": &mermaid, "a.puml": &puml, "a.json": &js} {
+		if err := writeChanTrace(buf, name, events); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"sequenceDiagram", "actor G1", "participant chan1", "G1->>chan1: send chan1 (0s)", "chan1->>G2: select chan1 (0s)", "done->>G2: select context canceled"} {
+		if !strings.Contains(mermaid.String(), want) {
+			t.Errorf("Mermaid diagram is missing %q:\n%s", want, mermaid.String())
+		}
+	}
+	for _, want := range []string{"@startuml", "queue chan2", "G3 -> chan2 : send chan2 (1s)", "@enduml"} {
+		if !strings.Contains(puml.String(), want) {
+			t.Errorf("PlantUML diagram is missing %q:\n%s", want, puml.String())
+		}
+	}
+	var decoded []chanEvent
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(events) || decoded[0] != events[0] {
+		t.Errorf("JSON round trip gave %v, want %v", decoded, events)
+	}
+
+	if err := writeChanTrace(io.Discard, "a.txt", events); err == nil {
+		t.Error("unknown extension accepted")
+	}
+}
+
+func TestChanTraceOffByDefault(t *testing.T) {
+	ec := newExampleContext(io.Discard)
+	ec.traceSend("c", 1) // must not panic without a tracer
+	if ec.trace != nil {
+		t.Fatal("tracing is on by default")
+	}
+}
+
+// TestChanTraceRangeClose checks that the pipeline behind range-close records every
+// send, the close of its output and every receive of the range loop.
+func TestChanTraceRangeClose(t *testing.T) {
+	count := map[string]int{}
+	closedAt := -1
+	for i, e := range tracedRun("range-close") {
+		if e.Channel != "c" {
+			t.Errorf("event on unexpected channel %q", e.Channel)
+		}
+		count[e.Op]++
+		if e.Op == "close" {
+			closedAt = i
+		} else if e.Op == "send" && closedAt >= 0 {
+			t.Errorf("send %v recorded after the close", e.Value)
+		}
+	}
+	if count["send"] != 10 || count["recv"] != 10 || count["close"] != 1 {
+		t.Errorf("got %v, want 10 sends, 10 receives and 1 close", count)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The visualizer replays channel operations on a simChan and draws the hchan after every step.
+/* go-concepts visualize replays what make(chan int, 3) does when senders outrun receivers:
+
+          0     1     2
+       +-----+-----+-----+
+  buf  |  1  |  2  |  3  |
+       +-----+-----+-----+
+ sendx    ^
+ recvx    ^
+ qcount=3 dataqsiz=3 closed=false
+ sendq: S4(4)
+ recvq: -
+
+Every operation runs on its own goroutine (S1, S2... for sends, R1, R2... for receives),
+so a send into a full buffer really parks on sendq until a receive makes room.
+
+	go-concepts visualize                                 make(chan int, 3) with blocked senders and receivers
+	go-concepts visualize -scenario buffered-channel      the sends and receives of bufferedChannel()
+	go-concepts visualize -cap 2 -ops send,send,send,recv,recv,recv
+	go-concepts visualize -html buffer.html               also write an animated SVG page
+*/
+// maxVizCap bounds -cap: every slot of the buffer is drawn on one line.
+const maxVizCap = 32
+
+type vizScenario struct {
+	cap         uint
+	ops         string
+	description string
+}
+
+var vizScenarios = map[string]vizScenario{
+	"make3": {3, "send,send,send,send,send,recv,recv,recv,recv,recv,recv,send,close",
+		"ch := make(chan int, 3): fill the buffer, park two senders, drain it, park a receiver"},
+	"buffered-channel": {2, "send:buffer 1,send:buffer 2,recv,recv",
+		"bufferedChannel(): two sends into make(chan string, 2), then two receives"},
+	"unbuffered": {0, "recv,send:test chan,send:again,recv",
+		"ch := make(chan string): every send waits for a receiver"},
+}
+
+// vizOp is one replayed channel operation.
+type vizOp struct {
+	kind  string // "send", "recv" or "close"
+	value string
+}
+
+// vizFrame is the channel's state after one step.
+type vizFrame struct {
+	step  hchanStep[string]
+	title string
+}
+
+func parseVizOps(spec string) ([]vizOp, error) {
+	var ops []vizOp
+	next := 1
+	for _, tok := range strings.Split(spec, ",") {
+		kind, value, hasValue := strings.Cut(strings.TrimSpace(tok), ":")
+		switch kind {
+		case "send":
+			if !hasValue {
+				value = fmt.Sprint(next)
+			}
+			next++
+		case "recv", "close":
+			if hasValue {
+				return nil, fmt.Errorf("%s takes no value: %q", kind, tok)
+			}
+		default:
+			return nil, fmt.Errorf("unknown operation %q (want send, send:value, recv or close)", tok)
+		}
+		ops = append(ops, vizOp{kind: kind, value: value})
+	}
+	return ops, nil
+}
+
+// replayChannel runs ops one at a time against a simulated channel of the given
+// capacity and returns a frame for every step. Each operation runs on its own
+// goroutine; the replay moves on once the operation has completed, parked or panicked.
+// Goroutines still parked at the end are released by closing the channel, which is
+// not recorded as a step, so that a replay leaves no goroutine behind.
+func replayChannel(capacity uint, ops []vizOp) []vizFrame {
+	ch := newSimChan[string](capacity)
+	var frames []vizFrame
+	ch.onStep = func(s hchanStep[string]) {
+		frames = append(frames, vizFrame{step: s})
+	}
+
+	var wg sync.WaitGroup
+	sends, recvs := 0, 0
+	for _, op := range ops {
+		var g string
+		switch op.kind {
+		case "send":
+			sends++
+			g = fmt.Sprintf("S%d", sends)
+		case "recv":
+			recvs++
+			g = fmt.Sprintf("R%d", recvs)
+		case "close":
+			g = "main"
+		}
+
+		ch.lock.Lock()
+		before := len(frames)
+		ch.lock.Unlock()
+
+		wg.Add(1)
+		go func(op vizOp, g string) {
+			defer wg.Done()
+			// Sending on or closing a closed channel panics, as in the runtime, and gets a
+			// frame of its own. A parked sender panics too when the channel is closed under
+			// it; by then it has had its frame, and the close frame says it was woken.
+			defer func() {
+				if r := recover(); r != nil {
+					ch.lock.Lock()
+					if len(frames) == before {
+						ch.step(op.kind, g, "panic: %v", r)
+					}
+					ch.lock.Unlock()
+				}
+			}()
+			switch op.kind {
+			case "send":
+				ch.send(g, op.value)
+			case "recv":
+				ch.recv(g)
+			case "close":
+				ch.close(g)
+			}
+		}(op, g)
+
+		ch.lock.Lock()
+		for len(frames) == before {
+			ch.changed.Wait()
+		}
+		frames[before].title = fmt.Sprintf("%s: %s", g, vizOpString(op))
+		ch.lock.Unlock()
+	}
+
+	ch.lock.Lock()
+	ch.onStep = nil
+	closed := ch.closed
+	ch.lock.Unlock()
+	if !closed {
+		ch.close("replay")
+	}
+	wg.Wait()
+	return frames
+}
+
+func vizOpString(op vizOp) string {
+	switch op.kind {
+	case "send":
+		return fmt.Sprintf("ch <- %s", op.value)
+	case "recv":
+		return "<-ch"
+	}
+	return "close(ch)"
+}
+
+// renderASCII draws one frame as text.
+func renderASCII(w io.Writer, n int, f vizFrame) {
+	st := f.step.state
+	fmt.Fprintf(w, "Step %d  %s\n  %s\n", n, f.title, f.step.note)
+
+	if st.dataqsiz == 0 {
+		fmt.Fprintln(w, "  buf   (unbuffered: no circular queue)")
+	} else {
+		width := 5
+		for _, v := range st.buf {
+			if len(v)+2 > width {
+				width = len(v) + 2
+			}
+		}
+		border := "       +" + strings.Repeat(strings.Repeat("-", width)+"+", len(st.buf))
+		var index, slots strings.Builder
+		index.WriteString("       ")
+		slots.WriteString("  buf  |")
+		for i, v := range st.buf {
+			if !st.occupied[i] {
+				v = ""
+			}
+			index.WriteString(" " + center(fmt.Sprint(i), width))
+			slots.WriteString(center(v, width) + "|")
+		}
+		fmt.Fprintln(w, strings.TrimRight(index.String(), " "))
+		fmt.Fprintln(w, border)
+		fmt.Fprintln(w, slots.String())
+		fmt.Fprintln(w, border)
+		caret := func(label string, i uint) {
+			pad := 8 + int(i)*(width+1) + width/2
+			fmt.Fprintf(w, " %-6s%s^\n", label, strings.Repeat(" ", pad-7))
+		}
+		caret("sendx", st.sendx)
+		caret("recvx", st.recvx)
+	}
+
+	fmt.Fprintf(w, "  qcount=%d dataqsiz=%d closed=%t\n", st.qcount, st.dataqsiz, st.closed)
+	fmt.Fprintf(w, "  sendq: %s\n", parkedList(st.sendq, true))
+	fmt.Fprintf(w, "  recvq: %s\n\n", parkedList(st.recvq, false))
+}
+
+func center(s string, width int) string {
+	left := (width - len(s)) / 2
+	if left < 0 {
+		left = 0
+	}
+	right := width - len(s) - left
+	if right < 0 {
+		right = 0
+	}
+	return strings.Repeat(" ", left) + s + strings.Repeat(" ", right)
+}
+
+func parkedList(q []parkedG[string], withValue bool) string {
+	if len(q) == 0 {
+		return "-"
+	}
+	var names []string
+	for _, p := range q {
+		if withValue {
+			names = append(names, fmt.Sprintf("%s(%s)", p.g, p.elem))
+		} else {
+			names = append(names, p.g)
+		}
+	}
+	return strings.Join(names, " -> ")
+}
+
+// writeVizHTML writes a standalone page that animates the frames as SVG.
+func writeVizHTML(w io.Writer, title string, frames []vizFrame) error {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString(`<style>
+body { font-family: sans-serif; margin: 2em; }
+svg { display: none; } svg.current { display: block; }
+text { font-family: monospace; font-size: 14px; }
+.slot { fill: #f4f4f4; stroke: #333; } .slot.full { fill: #9fd3f5; }
+.queue { fill: #fde2b3; stroke: #333; } .closed { fill: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+`)
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(title))
+	b.WriteString(`<p><button onclick="show(cur-1)">&larr; prev</button>
+<button id="play" onclick="toggle()">play</button>
+<button onclick="show(cur+1)">next &rarr;</button>
+<span id="counter"></span></p>
+`)
+	for i, f := range frames {
+		writeVizSVG(&b, i+1, f)
+	}
+	b.WriteString(`<script>
+var frames = document.querySelectorAll("svg"), cur = 0, timer = null;
+function show(i) {
+  if (i < 0 || i >= frames.length) return;
+  frames[cur].classList.remove("current");
+  cur = i;
+  frames[cur].classList.add("current");
+  document.getElementById("counter").textContent = "step " + (cur+1) + " of " + frames.length;
+}
+function toggle() {
+  if (timer) { clearInterval(timer); timer = null; document.getElementById("play").textContent = "play"; return; }
+  document.getElementById("play").textContent = "pause";
+  timer = setInterval(function() { if (cur+1 >= frames.length) { toggle(); } else { show(cur+1); } }, 1200);
+}
+show(0);
+</script>
+</body>
+</html>
+`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeVizSVG(b *strings.Builder, n int, f vizFrame) {
+	const slotW, slotH, x0, y0 = 70, 40, 40, 90
+	st := f.step.state
+	width := x0*2 + slotW*int(st.dataqsiz)
+	if width < 640 {
+		width = 640
+	}
+	fmt.Fprintf(b, "<svg width=\"%d\" height=\"300\" xmlns=\"http://www.w3.org/2000/svg\">\n", width)
+	fmt.Fprintf(b, "<text x=\"10\" y=\"20\">Step %d  %s</text>\n", n, html.EscapeString(f.title))
+	fmt.Fprintf(b, "<text x=\"10\" y=\"40\">%s</text>\n", html.EscapeString(f.step.note))
+
+	if st.dataqsiz == 0 {
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\">unbuffered: no circular queue</text>\n", x0, y0+25)
+	}
+	for i := 0; i < int(st.dataqsiz); i++ {
+		x := x0 + i*slotW
+		class := "slot"
+		value := ""
+		if st.occupied[i] {
+			class, value = "slot full", st.buf[i]
+		}
+		fmt.Fprintf(b, "<rect class=\"%s\" x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"/>\n", class, x, y0, slotW, slotH)
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", x+slotW/2, y0+25, html.EscapeString(value))
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%d</text>\n", x+slotW/2, y0-8, i)
+	}
+	if st.dataqsiz > 0 {
+		sx := x0 + int(st.sendx)*slotW + slotW/2
+		rx := x0 + int(st.recvx)*slotW + slotW/2
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">&#9650; sendx</text>\n", sx, y0+slotH+20)
+		fmt.Fprintf(b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">&#9650; recvx</text>\n", rx, y0+slotH+40)
+	}
+
+	fmt.Fprintf(b, "<text x=\"10\" y=\"200\">qcount=%d dataqsiz=%d</text>\n", st.qcount, st.dataqsiz)
+	if st.closed {
+		b.WriteString("<text class=\"closed\" x=\"200\" y=\"200\">closed</text>\n")
+	}
+	writeVizQueue(b, "sendq", 230, parkedList(st.sendq, true))
+	writeVizQueue(b, "recvq", 265, parkedList(st.recvq, false))
+	b.WriteString("</svg>\n")
+}
+
+func writeVizQueue(b *strings.Builder, label string, y int, list string) {
+	fmt.Fprintf(b, "<text x=\"10\" y=\"%d\">%s</text>\n", y, label)
+	if list == "-" {
+		return
+	}
+	fmt.Fprintf(b, "<rect class=\"queue\" x=\"70\" y=\"%d\" width=\"%d\" height=\"22\"/>\n", y-16, 12+9*len(list))
+	fmt.Fprintf(b, "<text x=\"76\" y=\"%d\">%s</text>\n", y, html.EscapeString(list))
+}
+
+func visualizeCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("visualize", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	scenario := fs.String("scenario", "make3", "built-in scenario: make3, buffered-channel or unbuffered")
+	capacity := fs.Uint("cap", 3, "channel capacity for -ops")
+	opsSpec := fs.String("ops", "", "comma-separated operations: send, send:value, recv, close")
+	htmlOut := fs.String("html", "", "also write an animated SVG/HTML page to this file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	capSet := false
+	fs.Visit(func(f *flag.Flag) { capSet = capSet || f.Name == "cap" })
+	if capSet && *opsSpec == "" {
+		fmt.Fprintln(stderr, "visualize: -cap only applies to -ops")
+		return 2
+	}
+	if *capacity > maxVizCap {
+		fmt.Fprintf(stderr, "visualize: -cap %d is too large, the most that can be drawn is %d\n", *capacity, maxVizCap)
+		return 2
+	}
+
+	sc, ok := vizScenarios[*scenario]
+	if !ok {
+		fmt.Fprintf(stderr, "visualize: unknown scenario %q\n", *scenario)
+		return 2
+	}
+	if *opsSpec != "" {
+		sc = vizScenario{cap: *capacity, ops: *opsSpec,
+			description: fmt.Sprintf("ch := make(chan string, %d): %s", *capacity, *opsSpec)}
+	}
+	ops, err := parseVizOps(sc.ops)
+	if err != nil {
+		fmt.Fprintln(stderr, "visualize:", err)
+		return 2
+	}
+
+	frames := replayChannel(sc.cap, ops)
+	fmt.Fprintf(stdout, "%s\n\n", sc.description)
+	for i, f := range frames {
+		renderASCII(stdout, i+1, f)
+	}
+
+	if *htmlOut != "" {
+		f, err := os.Create(*htmlOut)
+		if err != nil {
+			fmt.Fprintln(stderr, "visualize:", err)
+			return 1
+		}
+		if err := writeVizHTML(f, sc.description, frames); err != nil {
+			f.Close()
+			fmt.Fprintln(stderr, "visualize:", err)
+			return 1
+		}
+		if err := f.Close(); err != nil {
+			fmt.Fprintln(stderr, "visualize:", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", *htmlOut)
+	}
+	return 0
+}
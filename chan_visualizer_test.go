@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayChannelOneFramePerOperation(t *testing.T) {
+	sc := vizScenarios["make3"]
+	ops, err := parseVizOps(sc.ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := replayChannel(sc.cap, ops)
+	if len(frames) != len(ops) {
+		t.Fatalf("got %d frames for %d operations", len(frames), len(ops))
+	}
+
+	// The fourth send finds the buffer full and parks.
+	if st := frames[3].step.state; len(st.sendq) != 1 || st.sendq[0].g != "S4" || st.qcount != 3 {
+		t.Errorf("frame 4 = %v, want S4 parked on a full buffer", st)
+	}
+	// The first receive takes 1 and moves S4's 4 into the freed slot.
+	if st := frames[5].step.state; st.buf[0] != "4" || len(st.sendq) != 1 || st.sendx != 1 || st.recvx != 1 {
+		t.Errorf("frame 6 = %v, want 4 moved into buf[0]", st)
+	}
+	if last := frames[len(frames)-1].step.state; !last.closed {
+		t.Errorf("last frame = %v, want the channel closed", last)
+	}
+}
+
+func TestRenderASCII(t *testing.T) {
+	ops, _ := parseVizOps("send:a,send:b,recv")
+	frames := replayChannel(2, ops)
+
+	var buf bytes.Buffer
+	renderASCII(&buf, 3, frames[2])
+	want := `Step 3  R1: <-ch
+  took a from buf[0]
+          0     1
+       +-----+-----+
+  buf  |     |  b  |
+       +-----+-----+
+ sendx    ^
+ recvx          ^
+  qcount=1 dataqsiz=2 closed=false
+  sendq: -
+  recvq: -
+
+`
+	if got := buf.String(); got != want {
+		t.Errorf("renderASCII:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteVizHTML(t *testing.T) {
+	ops, _ := parseVizOps("send:<b>,recv")
+	var buf bytes.Buffer
+	if err := writeVizHTML(&buf, "test", replayChannel(1, ops)); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	if n := strings.Count(page, "<svg "); n != 2 {
+		t.Errorf("page has %d SVG frames, want 2", n)
+	}
+	if strings.Contains(page, "<b>") || !strings.Contains(page, "&lt;b&gt;") {
+		t.Error("channel values are not HTML-escaped")
+	}
+}
+
+func TestParseVizOpsRejectsUnknownOperations(t *testing.T) {
+	for _, spec := range []string{"send,peek", "recv:1", "close:x"} {
+		if _, err := parseVizOps(spec); err == nil {
+			t.Errorf("parseVizOps(%q) succeeded", spec)
+		}
+	}
+}
+
+// TestReplayChannelPanics checks that operations which panic get a frame instead of
+// leaving the replay waiting for one.
+func TestReplayChannelPanics(t *testing.T) {
+	for _, tt := range []struct {
+		ops  string
+		want string
+	}{
+		{"close,send", "panic: send on closed channel"},
+		{"close,close", "panic: close of closed channel"},
+	} {
+		ops, err := parseVizOps(tt.ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan []vizFrame)
+		go func() { done <- replayChannel(1, ops) }()
+		select {
+		case frames := <-done:
+			if len(frames) != 2 || frames[1].step.note != tt.want {
+				t.Errorf("%s: got frames %v, want the second one to say %q", tt.ops, frames, tt.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: replay did not finish", tt.ops)
+		}
+	}
+}
+
+// TestReplayChannelReleasesParkedGoroutines checks that senders and receivers still
+// parked when the operations run out do not outlive the replay.
+func TestReplayChannelReleasesParkedGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for _, spec := range []string{"send,send,send", "recv,recv"} {
+		ops, _ := parseVizOps(spec)
+		frames := replayChannel(1, ops)
+		if len(frames) != len(ops) {
+			t.Errorf("%s: got %d frames, want %d", spec, len(frames), len(ops))
+		}
+	}
+	// A released goroutine may take a moment to exit after its last deferred call.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left behind", n-before)
+	}
+}
+
+func TestVisualizeCommandRejectsCap(t *testing.T) {
+	for _, args := range [][]string{{"-cap", "1"}, {"-cap", "1000000", "-ops", "send"}} {
+		var stdout, stderr bytes.Buffer
+		if code := visualizeCommand(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%q: exit code %d, want 2", args, code)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"time"
 )
 
@@ -21,37 +21,46 @@ Like maps and slices, channels must be created before use:
 ch := make(chan int)
 By default, sends and receives block until the other side is ready. This allows goroutines to synchronize without explicit locks or condition variables.
 */
-func channelExample() {
-	fmt.Println("Start")
+func channelExample(ec *exampleContext) {
+	ec.println("Start")
 
 	msg := make(chan string)
 
-	go func() { msg <- "test chan" }()
+	go func() {
+		ec.traceSend("msg", "test chan")
+		msg <- "test chan"
+	}()
 	msgOut := <-msg
-	fmt.Println(msgOut)
+	ec.traceRecv("msg", msgOut)
+	ec.println(msgOut)
 
-	fmt.Println("Another Example")
+	ec.println("Another Example")
 	slice := []int{7, 9, 4, -11, 1, 0}
 	count := make(chan int)
-	fmt.Println("First half values ", slice[:len(slice)/2])
-	fmt.Println("Second half values ", slice[len(slice)/2:])
-	go sumMembers(slice[:len(slice)/2], count)
-	go sumMembers(slice[len(slice)/2:], count)
+	ec.println("First half values ", slice[:len(slice)/2])
+	ec.println("Second half values ", slice[len(slice)/2:])
+	go sumMembers(ec, slice[:len(slice)/2], count)
+	go sumMembers(ec, slice[len(slice)/2:], count)
 	x, y := <-count, <-count
-	fmt.Println("Values are: ", x, y, x+y)
+	ec.traceRecv("count", x)
+	ec.traceRecv("count", y)
+	ec.println("Values are: ", x, y, x+y)
 
-	bufferedChannel()
+	ec.step("buffered", func() { bufferedChannel(ec) })
 
-	selectExample()
+	ec.step("select", func() { selectExample(ec) })
 
-	rangeAndCloseChannel()
+	ec.step("range-close", func() { rangeAndCloseChannel(ec) })
 }
 
-func sumMembers(slice []int, count chan int) {
+// sumMembers sends the sum of slice on count. parallelReduce generalises it to any slice,
+// any associative combine function and any number of goroutines.
+func sumMembers(ec *exampleContext, slice []int, count chan int) {
 	sum := 0
 	for _, val := range slice {
 		sum += val
 	}
+	ec.traceSend("count", sum)
 	count <- sum
 }
 
@@ -63,14 +72,50 @@ Sends to a buffered channel block only when the buffer is full. Receives block w
 
 By default channels are unbuffered, meaning that they will only accept sends (chan <-) if there is a corresponding receive (<- chan) ready to receive the sent value.
 Buffered channels accept a limited number of values without a corresponding receiver for those values.
+
+Run "go-concepts visualize -scenario buffered-channel" to see the circular buffer change after every send and receive.
 */
-func bufferedChannel() {
-	fmt.Println("Buffered Channel Example")
+func bufferedChannel(ec *exampleContext) {
+	ec.println("Buffered Channel Example")
 	buffChan := make(chan string, 2)
+	ec.traceSend("buffChan", "buffer 1")
 	buffChan <- "buffer 1"
+	ec.traceSend("buffChan", "buffer 2")
 	buffChan <- "buffer 2"
-	fmt.Println(<-buffChan)
-	fmt.Println(<-buffChan)
+	first := <-buffChan
+	ec.traceRecv("buffChan", first)
+	ec.println(first)
+	second := <-buffChan
+	ec.traceRecv("buffChan", second)
+	ec.println(second)
+}
+
+// bufferedChannelDeadlock makes the mistake bufferedChannel avoids: a third send into a channel of capacity 2.
+/* With the buffer full and no receiver running, the send blocks forever, and so does the
+goroutine waiting for the example to finish. Run normally it dies with
+"fatal error: all goroutines are asleep - deadlock!"; run it with
+
+	go-concepts run --diagnose buffered-channel-deadlock
+
+to see which goroutine waits on which channel.
+*/
+func bufferedChannelDeadlock(ec *exampleContext) {
+	ec.println("Buffered Channel Deadlock Example")
+	buffChan := make(chan string, 2)
+	finished := make(chan bool)
+	go func() {
+		<-finished
+		ec.println("receiver done")
+	}()
+	buffChan <- "buffer 1"
+	buffChan <- "buffer 2"
+	// Receiving one value from outside makes room for the third send, and the rest
+	// of the example then runs to the end.
+	ec.onRelease(func() { <-buffChan })
+	ec.println("sending a third value into a channel of capacity", cap(buffChan))
+	buffChan <- "buffer 3"
+	ec.println(<-buffChan)
+	finished <- true
 }
 
 // The select statement lets a goroutine wait on multiple communication operations.
@@ -80,35 +125,47 @@ Go’s select lets you wait on multiple channel operations. Combining goroutines
 
 The default case in a select is run if no other case is ready.
 Use a default case to try a send or receive without blocking.
+
+The loop stops when its context is cancelled instead of on a dedicated quit channel.
+Sending "quit" on an unbuffered channel from the goroutine that runs the select would
+block forever, since the only receiver is that same goroutine: a deadlock. cancel() never
+blocks the caller, closes ctx.Done() for every goroutine waiting on it, and ctx.Err()
+says why the work stopped. select_context.go has variants for deadlines, timeouts and
+cancellation of child contexts.
 */
-func selectExample() {
-	fmt.Println("Select Example")
+func selectExample(ec *exampleContext) {
+	ec.println("Select Example")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	chan1 := make(chan string)
 	chan2 := make(chan string)
-	quit := make(chan string)
 	go func() {
-		time.Sleep(time.Second * 1)
+		ec.clock.Sleep(time.Second * 1)
+		ec.traceSend("chan1", "chan1")
 		chan1 <- "chan1"
 	}()
 	go func() {
-		time.Sleep(time.Second * 2)
+		ec.clock.Sleep(time.Second * 2)
+		ec.traceSend("chan2", "chan2")
 		chan2 <- "chan2"
-		quit <- "quit"
+		cancel()
 	}()
-	//quit <- "quit"
 
 	for {
 		select {
 		case msg := <-chan1:
-			fmt.Println(msg)
+			ec.traceSelect("chan1", msg)
+			ec.println(msg)
 		case msg := <-chan2:
-			fmt.Println(msg)
-		case msg := <-quit:
-			fmt.Println(msg)
+			ec.traceSelect("chan2", msg)
+			ec.println(msg)
+		case <-ctx.Done():
+			ec.traceSelect("done", ctx.Err())
+			ec.println("quit:", ctx.Err())
 			return
 		default:
-			time.Sleep(time.Millisecond * 500)
-			fmt.Println(".")
+			ec.clock.Sleep(time.Millisecond * 500)
+			ec.println(".")
 		}
 	}
 }
@@ -124,23 +181,28 @@ ok is false if there are no more values to receive and the channel is closed.
 The loop for i := range c receives values from the channel repeatedly until it is closed.
 
 Note: Only the sender should close a channel, never the receiver. Sending on a closed channel will cause a panic.
+
+The generator below does not close a channel itself: pipeGenerate (pipeline.go) owns
+the output channel and closes it once fibonacci returns, and pipeTake stops it after
+10 values, so the range in rangeAndCloseChannel ends when the last one is received.
 */
-func fibonacci(n int, c chan int) {
+func fibonacci(ctx context.Context, emit func(int) bool) error {
 	x, y := 0, 1
-	for i := 0; i < n; i++ {
-		c <- x
+	for emit(x) {
 		x, y = y, x+y
 	}
-	close(c)
+	return nil
 }
 
-func rangeAndCloseChannel() {
-	fmt.Println("rangeAndCloseChannel with fibonacci example")
-	c := make(chan int, 10)
-	go fibonacci(cap(c), c)
-	for i := range c {
-		fmt.Println(i)
+func rangeAndCloseChannel(ec *exampleContext) {
+	ec.println("rangeAndCloseChannel with fibonacci example")
+	p := newPipeline(context.Background())
+	c := pipeTake(pipeGenerate(p, fibonacci), 10, withBuffer(10), withTrace(ec, "c"))
+	for i := range c.c {
+		ec.traceRecv("c", i)
+		ec.println(i)
 	}
+	p.Wait()
 }
 
 /* Output:
@@ -162,7 +224,7 @@ Select Example
 chan1
 .
 chan2
-quit
+quit: context canceled
 rangeAndCloseChannel with fibonacci example
 0
 1
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const usageText = `Usage:
+  go-concepts list                 list the registered examples
+  go-concepts run <example>...     run one or more examples by name
+  go-concepts run --all            run every registered example
+  go-concepts visualize            replay buffered-channel operations step by step
+  go-concepts schedsim             simulate the G/M/P scheduler on a workload
+  go-concepts gcsim                simulate the tri-color mark and sweep collector
+  go-concepts escape <file|example> annotate source with the compiler's escape analysis
+  go-concepts layout <type>...     report struct field offsets, padding and a smaller field order
+  go-concepts methods <type>...    list the method sets of T and *T and the interfaces each satisfies
+
+Run flags:
+  --fake-clock                     run on a virtual clock, so sleeps finish instantly
+  --diagnose                       dump goroutines while running and explain what blocks them
+  --diagnose-every <duration>      interval between goroutine dumps (default 500ms)
+  --chan-trace <file>              record channel operations as JSON (.json), Mermaid (.mmd)
+                                   or PlantUML (.puml) sequence diagrams
+  --trace <file>                   write an execution trace for go tool trace, with a task per
+                                   example, and print goroutine, blocking and GC figures
+  --memstats                       print allocation, heap growth and GC deltas after each example
+  --memstats-json <file>           also write the memory reports as JSON, one object per example
+`
+
+// runCLI dispatches the subcommand in args and returns the process exit code.
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usageText)
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		listExamples(stdout)
+		return 0
+	case "run":
+		return runCommand(args[1:], stdout, stderr)
+	case "visualize":
+		return visualizeCommand(args[1:], stdout, stderr)
+	case "schedsim":
+		return schedsimCommand(args[1:], stdout, stderr)
+	case "gcsim":
+		return gcsimCommand(args[1:], stdout, stderr)
+	case "escape":
+		return escapeCommand(args[1:], stdout, stderr)
+	case "layout":
+		return layoutCommand(args[1:], stdout, stderr)
+	case "methods":
+		return methodsCommand(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usageText)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usageText)
+		return 2
+	}
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) (code int) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	all := fs.Bool("all", false, "run every registered example")
+	fakeClock := fs.Bool("fake-clock", false, "run on a virtual clock, so sleeps finish instantly")
+	diagnose := fs.Bool("diagnose", false, "dump goroutines while running and explain what blocks them")
+	chanTrace := fs.String("chan-trace", "", "record channel operations to `file` (.json, .mmd or .puml)")
+	traceFile := fs.String("trace", "", "write a runtime/trace execution trace to `file` and print a summary")
+	memstats := fs.Bool("memstats", false, "print allocation, heap and GC deltas after each example")
+	memJSON := fs.String("memstats-json", "", "write the --memstats reports to `file` as JSON (implies --memstats)")
+	diagnoseEvery := fs.Duration("diagnose-every", 500*time.Millisecond, "interval between goroutine dumps with --diagnose")
+
+	names, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if *diagnoseEvery <= 0 {
+		fmt.Fprintln(stderr, "run: --diagnose-every must be positive")
+		return 2
+	}
+	if *diagnose && *fakeClock {
+		// The diagnosis watches real goroutines on the real clock.
+		fmt.Fprintln(stderr, "run: --fake-clock cannot be combined with --diagnose")
+		return 2
+	}
+
+	var selected []example
+	switch {
+	case *all && len(names) > 0:
+		fmt.Fprintln(stderr, "run: --all cannot be combined with example names")
+		return 2
+	case *all:
+		selected = examples
+	case len(names) == 0:
+		fmt.Fprintf(stderr, "run: no example given\n\n%s", usageText)
+		return 2
+	default:
+		for _, name := range names {
+			ex, ok := lookupExample(name)
+			if !ok {
+				if ex, ok = lookupDeadlockExample(name); ok && !*diagnose {
+					fmt.Fprintf(stderr, "run: %s deadlocks on purpose, run it with --diagnose\n", name)
+					return 2
+				}
+			}
+			if !ok {
+				fmt.Fprintf(stderr, "run: unknown example %q (see go-concepts list)\n", name)
+				return 2
+			}
+			selected = append(selected, ex)
+		}
+	}
+
+	ec := newExampleContext(stdout)
+	if *chanTrace != "" {
+		if err := writeChanTrace(io.Discard, *chanTrace, nil); err != nil {
+			fmt.Fprintln(stderr, "run:", err)
+			return 2
+		}
+		ec.trace = &chanTracer{}
+		defer func() {
+			if err := saveChanTrace(*chanTrace, ec.trace.Events()); err != nil {
+				fmt.Fprintln(stderr, "run:", err)
+				code = 1
+			}
+		}()
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "run:", err)
+			return 2
+		}
+		if err := startTrace(f); err != nil {
+			f.Close()
+			fmt.Fprintln(stderr, "run:", err)
+			return 2
+		}
+		defer func() {
+			stopTrace()
+			if err := f.Close(); err != nil {
+				fmt.Fprintln(stderr, "run:", err)
+			}
+		}()
+	}
+	var memReports []memReport
+	if *memJSON != "" {
+		*memstats = true
+		f, err := os.Create(*memJSON)
+		if err != nil {
+			fmt.Fprintln(stderr, "run:", err)
+			return 2
+		}
+		defer func() {
+			err := writeMemJSON(f, memReports)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				fmt.Fprintln(stderr, "run:", err)
+				code = 1
+			}
+		}()
+	}
+	for _, ex := range selected {
+		ec.log.Printf("Running %s", ex.name)
+		finished := true
+		run := func() {
+			switch {
+			case *diagnose:
+				finished = runDiagnosed(ex, ec, stderr, *diagnoseEvery)
+			case *fakeClock:
+				fc := newFakeClock(time.Now())
+				ec.clock = fc
+				fc.run(func() { ex.run(ec) })
+			default:
+				ex.run(ec)
+			}
+		}
+		if *memstats {
+			measured := run
+			run = func() {
+				r := measureMemory(ex.name, measured)
+				memReports = append(memReports, r)
+				r.print(stderr)
+			}
+		}
+		if *traceFile != "" {
+			runTraced(ex, ec, run).print(stderr)
+		} else {
+			run()
+		}
+		if !finished {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, so both "run --all" and "run channels --all" work.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunCLI(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string // substring expected in stdout, if any
+		stderr string // substring expected in stderr, if any
+	}{
+		{"no command", nil, 2, "", "Usage:"},
+		{"help", []string{"help"}, 0, "Usage:", ""},
+		{"help flag", []string{"--help"}, 0, "Usage:", ""},
+		{"unknown command", []string{"frobnicate"}, 2, "", `unknown command "frobnicate"`},
+		{"list", []string{"list"}, 0, "range-close", ""},
+		{"run without examples", []string{"run"}, 2, "", "run: no example given"},
+		{"run unknown example", []string{"run", "nope"}, 2, "", `run: unknown example "nope"`},
+		{"run unknown flag", []string{"run", "--nope"}, 2, "", "flag provided but not defined: -nope"},
+		{"run all with names", []string{"run", "--all", "slices"}, 2, "", "--all cannot be combined"},
+		{"run deadlock without diagnose", []string{"run", "buffered-channel-deadlock"}, 2, "", "run it with --diagnose"},
+		{"run flag after name", []string{"run", "range-close", "--fake-clock"}, 0, "Running range-close", ""},
+		{"run zero diagnose interval", []string{"run", "--diagnose", "--diagnose-every", "0", "range-close"}, 2, "", "--diagnose-every must be positive"},
+		{"run fake clock with diagnose", []string{"run", "--diagnose", "--fake-clock", "range-close"}, 2, "", "--fake-clock cannot be combined with --diagnose"},
+		{"run unwritable memstats file", []string{"run", "--memstats-json", "/nonexistent/m.json", "range-close"}, 2, "", "run: open /nonexistent/m.json"},
+		{"run unwritable chan trace file", []string{"run", "--chan-trace", "/nonexistent/t.json", "range-close"}, 1, "", "run: open /nonexistent/t.json"},
+		{"layout without types", []string{"layout"}, 2, "", "usage: go-concepts layout"},
+		{"methods without types", []string{"methods"}, 2, "", "usage: go-concepts methods"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runCLI(tt.args, &stdout, &stderr); code != tt.code {
+				t.Errorf("exit code %d, want %d\nstderr: %s", code, tt.code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.stdout) {
+				t.Errorf("stdout is missing %q:\n%s", tt.stdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args       []string
+		positional []string
+		all        bool
+		n          int
+	}{
+		{nil, nil, false, 0},
+		{[]string{"a", "b"}, []string{"a", "b"}, false, 0},
+		{[]string{"-all", "a"}, []string{"a"}, true, 0},
+		{[]string{"a", "-n", "3", "b", "--all"}, []string{"a", "b"}, true, 3},
+		{[]string{"a", "-n=4"}, []string{"a"}, false, 4},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		all := fs.Bool("all", false, "")
+		n := fs.Int("n", 0, "")
+		positional, err := parseInterspersed(fs, tt.args)
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(positional, tt.positional) || *all != tt.all || *n != tt.n {
+			t.Errorf("%q: got %q all=%v n=%d, want %q all=%v n=%d", tt.args, positional, *all, *n, tt.positional, tt.all, tt.n)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Int("n", 0, "")
+	if _, err := parseInterspersed(fs, []string{"a", "-n", "x"}); err == nil {
+		t.Error("an invalid flag value was accepted")
+	}
+}
+
+// TestRunMemstatsJSON checks that --memstats-json prints the reports as --memstats
+// does and writes them to the file.
+func TestRunMemstatsJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.json")
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"run", "--memstats-json", path, "range-close"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "memstats for range-close") {
+		t.Errorf("stderr is missing the report:\n%s", stderr.String())
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []memReport
+	if err := json.Unmarshal(b, &reports); err != nil || len(reports) != 1 || reports[0].Example != "range-close" {
+		t.Errorf("%s = %s, %v; want one report for range-close", path, b, err)
+	}
+}
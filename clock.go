@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A clock tells time and waits, so examples that depend on time can run on a fake one.
+/* Examples call ec.clock.Sleep instead of time.Sleep, and ec.clock.After instead of time.After.
+The real clock just forwards to the time package.
+
+The fake clock only moves when it is told to. Sleeping goroutines wait until the
+test advances virtual time past their deadline:
+
+	fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+	go func() {
+		fc.Sleep(time.Second)
+		fmt.Println("woke up")
+	}()
+	fc.BlockUntil(1)        // wait for the goroutine to start sleeping
+	fc.Advance(time.Second) // "woke up" is printed, no real second passes
+
+Timers fire one at a time in deadline order; timers with the same deadline fire
+in the order they were created. After each one fires, the fake clock waits until
+every goroutine is blocked again before firing the next, so the interleaving of
+goroutines that wake at the same virtual instant is reproducible.
+
+fc.run(f) runs f and keeps advancing to the next deadline whenever every goroutine
+is blocked, so a whole example that sleeps for seconds finishes instantly.
+
+"Every goroutine is blocked" is read from a runtime.Stack dump of the whole process,
+which has two limits. Goroutines of unrelated code, such as parallel tests, count
+too, so they can delay the clock. And a goroutine waiting for the network shows as
+blocked ("IO wait") although the result will arrive without the clock's help, so
+the clock may fire a timer before it does: examples that do real I/O are only
+deterministic if they finish the I/O before relying on a timer. A goroutine busy
+computing is waited for up to settleLimit; if it is still running then, the clock
+panics rather than fire the next timer ahead of its work.
+*/
+type clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) clockTimer
+	NewTicker(d time.Duration) clockTicker
+	AfterFunc(d time.Duration, f func()) clockTimer
+}
+
+type clockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type clockTicker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) clockTimer    { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) clockTicker  { return realTicker{time.NewTicker(d)} }
+
+func (realClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// defaultSettleLimit bounds how long the fake clock waits for goroutines to block
+// before it fires a timer.
+const defaultSettleLimit = 10 * time.Second
+
+// fakeClock is a clock whose time only moves through Advance or run.
+type fakeClock struct {
+	mu          sync.Mutex
+	changed     *sync.Cond
+	now         time.Time
+	seq         uint64
+	timers      []*fakeTimer
+	settleLimit time.Duration
+}
+
+func newFakeClock(start time.Time) *fakeClock {
+	c := &fakeClock{now: start, settleLimit: defaultSettleLimit}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	c      chan time.Time
+	f      func()
+	when   time.Time
+	period time.Duration
+	seq    uint64
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-c.After(d)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.schedule(t, d)
+	return t
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) clockTicker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), period: d}
+	c.schedule(t, d)
+	return fakeTicker{t}
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	t := &fakeTimer{clock: c, f: f}
+	c.schedule(t, d)
+	return t
+}
+
+func (c *fakeClock) schedule(t *fakeTimer, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t.seq = c.seq
+	t.when = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.changed.Broadcast()
+}
+
+// remove unschedules t and reports whether it was pending. c.mu must be held.
+func (c *fakeClock) remove(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	t.clock.schedule(t, d)
+	return active
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (tk fakeTicker) C() <-chan time.Time { return tk.t.c }
+func (tk fakeTicker) Stop()               { tk.t.Stop() }
+
+// BlockUntil waits until at least n timers, sleeps included, are pending.
+func (c *fakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// Advance moves the clock forward by d, firing every timer that falls due on the way.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.settle()
+		if !c.fireNext(target) {
+			break
+		}
+	}
+
+	c.mu.Lock()
+	if c.now.Before(target) {
+		c.now = target
+	}
+	c.mu.Unlock()
+}
+
+// settle waits until every other goroutine is blocked. It panics if some are still
+// running after c.settleLimit: firing a timer then would depend on how fast they are.
+func (c *fakeClock) settle() {
+	if !waitForIdle(c.settleLimit) {
+		panic(fmt.Sprintf("fake clock: goroutines still running after %v, virtual time cannot move on deterministically", c.settleLimit))
+	}
+}
+
+// next returns the timer that fires first. c.mu must be held.
+func (c *fakeClock) next() *fakeTimer {
+	var first *fakeTimer
+	for _, t := range c.timers {
+		if first == nil || t.when.Before(first.when) || (t.when.Equal(first.when) && t.seq < first.seq) {
+			first = t
+		}
+	}
+	return first
+}
+
+// fireNext fires the earliest timer due at or before target, and reports whether there was one.
+func (c *fakeClock) fireNext(target time.Time) bool {
+	c.mu.Lock()
+	t := c.next()
+	if t == nil || t.when.After(target) {
+		c.mu.Unlock()
+		return false
+	}
+	c.remove(t)
+	c.now = t.when
+	now := c.now
+	if t.period > 0 {
+		c.seq++
+		t.seq = c.seq
+		t.when = t.when.Add(t.period)
+		c.timers = append(c.timers, t)
+	}
+	c.mu.Unlock()
+
+	if t.f != nil {
+		go t.f()
+		return true
+	}
+	// Like the time package, drop the tick if the last one was not received yet.
+	select {
+	case t.c <- now:
+	default:
+	}
+	return true
+}
+
+// run calls f and advances the clock to the next deadline every time all
+// goroutines are blocked, until f returns.
+func (c *fakeClock) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	for {
+		// Advance checks that the wait did not time out before it fires anything.
+		waitForIdle(c.settleLimit)
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		c.mu.Lock()
+		t := c.next()
+		c.mu.Unlock()
+		if t == nil {
+			// Nothing to fire: f is waiting on something other than the clock.
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			continue
+		}
+		c.Advance(t.when.Sub(c.Now()))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// withClockDeadline is context.WithDeadline measured on clk instead of the time package.
+/* context.WithDeadline and context.WithTimeout always use the real clock, so an example
+built on them would wait real seconds even under --fake-clock. With the real clock these
+helpers return the standard library contexts; with a fake clock they return a clockContext
+whose deadline is a clk.AfterFunc timer.
+
+Like the standard library contexts, a clockContext is done when
+  - its deadline passes: Err returns context.DeadlineExceeded,
+  - its cancel function is called: Err returns context.Canceled,
+  - its parent is done: Err returns the parent's error.
+*/
+func withClockDeadline(parent context.Context, clk clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := clk.(realClock); ok {
+		return context.WithDeadline(parent, deadline)
+	}
+	if pd, ok := parent.Deadline(); ok && pd.Before(deadline) {
+		deadline = pd
+	}
+
+	c := &clockContext{parent: parent, deadline: deadline, done: make(chan struct{})}
+	d := deadline.Sub(clk.Now())
+	if d <= 0 {
+		c.cancel(context.DeadlineExceeded)
+		return c, func() {}
+	}
+	c.mu.Lock()
+	c.timer = clk.AfterFunc(d, func() { c.cancel(context.DeadlineExceeded) })
+	c.mu.Unlock()
+	if parent.Done() != nil {
+		go func() {
+			select {
+			case <-parent.Done():
+				c.cancel(parent.Err())
+			case <-c.done:
+			}
+		}()
+	}
+	return c, func() { c.cancel(context.Canceled) }
+}
+
+// withClockTimeout is context.WithTimeout measured on clk.
+func withClockTimeout(parent context.Context, clk clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return withClockDeadline(parent, clk, clk.Now().Add(timeout))
+}
+
+// clockContext is a context whose deadline is a timer on a clock.
+type clockContext struct {
+	parent   context.Context
+	deadline time.Time
+	done     chan struct{}
+	timer    clockTimer
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *clockContext) Deadline() (time.Time, bool)       { return c.deadline, true }
+func (c *clockContext) Done() <-chan struct{}             { return c.done }
+func (c *clockContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func (c *clockContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *clockContext) String() string {
+	return "clockContext.WithDeadline(" + c.deadline.String() + ")"
+}
+
+// cancel records err, unless the context is already done, and closes Done.
+func (c *clockContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var clockStart = time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC)
+
+func TestFakeClockSleepersWakeInDeadlineOrder(t *testing.T) {
+	fc := newFakeClock(clockStart)
+	var mu sync.Mutex
+	var woke []string
+	var wg sync.WaitGroup
+	sleep := func(name string, d time.Duration) {
+		defer wg.Done()
+		fc.Sleep(d)
+		mu.Lock()
+		woke = append(woke, name)
+		mu.Unlock()
+	}
+
+	wg.Add(3)
+	go sleep("2s", 2*time.Second)
+	fc.BlockUntil(1)
+	go sleep("1s", time.Second)
+	fc.BlockUntil(2)
+	go sleep("2s later", 2*time.Second)
+	fc.BlockUntil(3)
+
+	fc.Advance(1500 * time.Millisecond)
+	mu.Lock()
+	if want := []string{"1s"}; !reflect.DeepEqual(woke, want) {
+		t.Errorf("after 1.5s woke = %v, want %v", woke, want)
+	}
+	mu.Unlock()
+
+	fc.Advance(500 * time.Millisecond)
+	wg.Wait()
+	if want := []string{"1s", "2s", "2s later"}; !reflect.DeepEqual(woke, want) {
+		t.Errorf("woke = %v, want %v", woke, want)
+	}
+	if got, want := fc.Now(), clockStart.Add(2*time.Second); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
+
+func TestFakeClockTimerStopAndReset(t *testing.T) {
+	fc := newFakeClock(clockStart)
+	timer := fc.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("Stop on a pending timer returned false")
+	}
+	fc.Advance(2 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	if timer.Reset(time.Second) {
+		t.Error("Reset on a stopped timer returned true")
+	}
+	fc.Advance(time.Second)
+	select {
+	case now := <-timer.C():
+		if want := clockStart.Add(3 * time.Second); !now.Equal(want) {
+			t.Errorf("timer fired at %v, want %v", now, want)
+		}
+	default:
+		t.Fatal("reset timer did not fire")
+	}
+}
+
+func TestFakeClockTickerAndAfterFunc(t *testing.T) {
+	fc := newFakeClock(clockStart)
+	ticker := fc.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	called := make(chan time.Time, 1)
+	fc.AfterFunc(2500*time.Millisecond, func() { called <- fc.Now() })
+
+	for i := 1; i <= 3; i++ {
+		fc.Advance(time.Second)
+		select {
+		case tick := <-ticker.C():
+			if want := clockStart.Add(time.Duration(i) * time.Second); !tick.Equal(want) {
+				t.Errorf("tick %d at %v, want %v", i, tick, want)
+			}
+		default:
+			t.Fatalf("no tick after %ds", i)
+		}
+	}
+
+	select {
+	case at := <-called:
+		if want := clockStart.Add(2500 * time.Millisecond); !at.Equal(want) {
+			t.Errorf("AfterFunc ran at %v, want %v", at, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AfterFunc did not run")
+	}
+}
+
+func TestFakeClockRunFinishesInstantly(t *testing.T) {
+	fc := newFakeClock(clockStart)
+	start := time.Now()
+	fc.run(func() {
+		fc.Sleep(time.Hour)
+		<-fc.After(time.Hour)
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("two virtual hours took %v of real time", elapsed)
+	}
+	if got, want := fc.Now(), clockStart.Add(2*time.Hour); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
+
+// TestFakeClockPanicsOnBusyGoroutine checks that the clock refuses to fire a timer
+// while a goroutine is still computing, instead of moving time ahead of it.
+func TestFakeClockPanicsOnBusyGoroutine(t *testing.T) {
+	fc := newFakeClock(clockStart)
+	fc.settleLimit = 50 * time.Millisecond
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}()
+	fc.NewTimer(time.Second)
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "goroutines still running") {
+			t.Errorf("Advance recovered %v, want a panic about running goroutines", r)
+		}
+	}()
+	fc.Advance(time.Second)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// run --diagnose watches an example and explains what its goroutines are blocked on.
+/* When every goroutine waits on a channel, the runtime gives up with
+
+	fatal error: all goroutines are asleep - deadlock!
+
+and a wall of stacks. In diagnose mode the example runs while a monitor dumps all
+goroutine stacks every interval. Goroutines blocked on the same operation at the same
+place for two dumps in a row are reported, grouped by the operation they wait in,
+with the source line they wait at:
+
+	go-concepts run --diagnose buffered-channel-deadlock
+
+	diagnose: every goroutine of the example is blocked - deadlock
+	chan receive (1 goroutine):
+	  goroutine 8  main.bufferedChannelDeadlock.func1  channels.go:107  <-finished
+	chan send (1 goroutine):
+	  goroutine 7  main.bufferedChannelDeadlock  channels.go:116  buffChan <- "buffer 3"
+
+The monitor keeps the runtime's own deadlock detector from firing, so when every goroutine
+of the example is stuck it prints the summary and the run fails instead.
+*/
+type blockedOp struct {
+	id       int
+	op       string // "chan send", "chan receive", "select", "sync.Mutex.Lock", ...
+	function string // innermost function of this program on the stack
+	file     string // source file of that function
+	line     int
+	source   string // the statement at file:line, when the source is available
+}
+
+// deadlockExamples deadlock on purpose. They can only be run with --diagnose.
+var deadlockExamples = []example{
+	{"buffered-channel-deadlock", "a third send into a buffered channel of capacity 2", bufferedChannelDeadlock},
+}
+
+// onRelease records how the goroutines of a deadlock example can be unblocked once
+// the deadlock has been diagnosed, so a test does not leave them behind.
+func (ec *exampleContext) onRelease(f func()) {
+	ec.releaseMu.Lock()
+	ec.release = f
+	ec.releaseMu.Unlock()
+}
+
+// releaseDeadlock unblocks the example, if it registered a way to do so.
+func (ec *exampleContext) releaseDeadlock() {
+	ec.releaseMu.Lock()
+	f := ec.release
+	ec.release = nil
+	ec.releaseMu.Unlock()
+	if f != nil {
+		f()
+	}
+}
+
+// lookupDeadlockExample returns the deadlock example with the given name.
+func lookupDeadlockExample(name string) (example, bool) {
+	for _, ex := range deadlockExamples {
+		if ex.name == name {
+			return ex, true
+		}
+	}
+	return example{}, false
+}
+
+// ownPackage is the prefix of this program's function names in stack traces:
+// "main." in the binary, the module path in tests.
+var ownPackage = func() string {
+	name := runtime.FuncForPC(reflect.ValueOf(lookupDeadlockExample).Pointer()).Name()
+	return name[:strings.LastIndex(name, ".")+1]
+}()
+
+// describeBlocked finds the operation g waits in and where in this program it waits.
+func describeBlocked(g goroutineInfo) blockedOp {
+	b := blockedOp{id: g.id, op: g.state}
+	lines := strings.Split(strings.TrimSpace(g.stack), "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		fn, pos := lines[i], strings.TrimSpace(lines[i+1])
+		if strings.HasPrefix(fn, "created by ") {
+			break
+		}
+		if !strings.HasPrefix(fn, ownPackage) {
+			continue
+		}
+		if p := strings.LastIndex(fn, "("); p >= 0 {
+			fn = fn[:p]
+		}
+		b.function = "main." + strings.TrimPrefix(fn, ownPackage)
+		if sp := strings.LastIndex(pos, " +0x"); sp >= 0 {
+			pos = pos[:sp]
+		}
+		if c := strings.LastIndex(pos, ":"); c >= 0 {
+			b.file = pos[:c]
+			b.line, _ = strconv.Atoi(pos[c+1:])
+		}
+		b.source = sourceLine(b.file, b.line)
+		break
+	}
+	return b
+}
+
+// sourceLine returns line n of file without its indentation, or "" if it cannot be read.
+func sourceLine(file string, n int) string {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(src), "\n")
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[n-1])
+}
+
+// waitsOnChannel reports whether op is a wait that only another goroutine can end.
+func (b blockedOp) waitsOnChannel() bool {
+	switch b.op {
+	case "chan send", "chan receive", "select", "select (no cases)", "chan send (nil chan)", "chan receive (nil chan)":
+		return true
+	}
+	return strings.HasPrefix(b.op, "sync.") || b.op == "semacquire"
+}
+
+// diagnoseSummary groups blocked goroutines by the operation they wait in.
+func diagnoseSummary(ops []blockedOp) string {
+	groups := map[string][]blockedOp{}
+	for _, b := range ops {
+		groups[b.op] = append(groups[b.op], b)
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s (%d goroutine", k, len(groups[k]))
+		if len(groups[k]) > 1 {
+			sb.WriteString("s")
+		}
+		sb.WriteString("):\n")
+		for _, b := range groups[k] {
+			fmt.Fprintf(&sb, "  goroutine %d  %s  %s:%d  %s\n", b.id, b.function, filepath.Base(b.file), b.line, b.source)
+		}
+	}
+	return sb.String()
+}
+
+// runDiagnosed runs ex while dumping goroutines every interval. It reports whether the
+// example finished; if every goroutine it started stays blocked, it prints why and gives up.
+func runDiagnosed(ex example, ec *exampleContext, w io.Writer, every time.Duration) bool {
+	before := snapshotGoroutines()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ex.run(ec)
+	}()
+
+	self := currentGoroutineID()
+	var last, reported string
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return true
+		case <-tick.C:
+		}
+
+		var blocked []blockedOp
+		running := false
+		for _, g := range parseGoroutines(dumpGoroutines()) {
+			if before[g.id] || g.id == self {
+				continue
+			}
+			b := describeBlocked(g)
+			if !g.blocked() || !b.waitsOnChannel() {
+				running = true
+				continue
+			}
+			blocked = append(blocked, b)
+		}
+		summary := diagnoseSummary(blocked)
+		switch {
+		case summary == "" || summary != last:
+			// Nothing blocked, or the goroutines have moved since the last dump.
+		case !running:
+			fmt.Fprintf(w, "diagnose: every goroutine of the example is blocked - deadlock\n%s", summary)
+			return false
+		case summary != reported:
+			fmt.Fprintf(w, "diagnose: goroutines blocked for %v\n%s", every, summary)
+			reported = summary
+		}
+		last = summary
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeBlocked(t *testing.T) {
+	g := goroutineInfo{id: 7, state: "chan send", stack: ownPackage + `bufferedChannelDeadlock(0xc000012345)
+	/src/channels.go:100 +0x1d5
+` + ownPackage + `runDiagnosed.func1()
+	/src/diagnose.go:160 +0x2c
+created by ` + ownPackage + `runDiagnosed in goroutine 1
+	/src/diagnose.go:158 +0xc5
+`}
+	b := describeBlocked(g)
+	if b.function != "main.bufferedChannelDeadlock" || b.file != "/src/channels.go" || b.line != 100 {
+		t.Errorf("describeBlocked = %+v", b)
+	}
+	if !b.waitsOnChannel() {
+		t.Errorf("%q should count as waiting on a channel", b.op)
+	}
+	if (blockedOp{op: "sleep"}).waitsOnChannel() {
+		t.Error("a sleeping goroutine wakes up on its own")
+	}
+}
+
+// TestDiagnoseDeadlock runs the deadlock example, then releases its goroutines so
+// they do not stay blocked for the rest of the test binary.
+func TestDiagnoseDeadlock(t *testing.T) {
+	ex, ok := lookupDeadlockExample("buffered-channel-deadlock")
+	if !ok {
+		t.Fatal("buffered-channel-deadlock is not registered")
+	}
+	before := snapshotGoroutines()
+	ec := newExampleContext(io.Discard)
+	t.Cleanup(func() {
+		ec.releaseDeadlock()
+		if leaks := before.leaked(time.Second); len(leaks) > 0 {
+			t.Error(formatLeaks(leaks))
+		}
+	})
+	var report bytes.Buffer
+	if runDiagnosed(ex, ec, &report, 20*time.Millisecond) {
+		t.Fatal("the deadlock example finished")
+	}
+	for _, want := range []string{
+		"deadlock",
+		"chan send (1 goroutine):",
+		`main.bufferedChannelDeadlock  channels.go:`,
+		`buffChan <- "buffer 3"`,
+		"chan receive (1 goroutine):",
+		"<-finished",
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, report.String())
+		}
+	}
+}
+
+func TestDiagnoseFinishedExample(t *testing.T) {
+	ex, _ := lookupExample("buffered-channel")
+	var report bytes.Buffer
+	if !runDiagnosed(ex, newExampleContext(io.Discard), &report, 20*time.Millisecond) {
+		t.Fatalf("buffered-channel did not finish:\n%s", report.String())
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The escape command shows the compiler's stack vs heap decisions next to the source.
+/* It builds the package with the compiler's escape diagnostics turned on,
+
+	go build -gcflags=-m -o /dev/null .
+
+and prints each line of the requested file or example with what the compiler said about it:
+
+	  30  	c := 0
+	      	^ moved to heap: c
+	  31  	return &c
+
+	go-concepts escape escape_examples.go     annotate a whole file
+	go-concepts escape goroutines             annotate the function behind a registered example
+	go-concepts escape -inline channels.go    also show the inlining decisions
+*/
+type escapeDiagnostic struct {
+	file string
+	line int
+	col  int
+	msg  string
+}
+
+var escapeLine = regexp.MustCompile(`^(.+\.go):(\d+):(\d+): (.+)$`)
+
+// runEscapeAnalysis compiles the package in dir with -gcflags=-m and returns its diagnostics.
+func runEscapeAnalysis(dir string, inline bool) ([]escapeDiagnostic, error) {
+	cmd := exec.Command("go", "build", "-gcflags=-m", "-o", os.DevNull, ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("go build failed: %v\n%s", err, out)
+	}
+	return parseEscapeOutput(out, inline), nil
+}
+
+// parseEscapeOutput turns the compiler's "file:line:col: message" lines into diagnostics,
+// dropping duplicates and, unless inline is set, the inlining notes.
+func parseEscapeOutput(out []byte, inline bool) []escapeDiagnostic {
+	var diags []escapeDiagnostic
+	seen := map[escapeDiagnostic]bool{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		m := escapeLine.FindStringSubmatch(sc.Text())
+		if m == nil {
+			continue
+		}
+		msg := m[4]
+		if !inline && (strings.HasPrefix(msg, "can inline ") || strings.HasPrefix(msg, "inlining call to ")) {
+			continue
+		}
+		line, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		d := escapeDiagnostic{file: filepath.Clean(m[1]), line: line, col: col, msg: msg}
+		if !seen[d] {
+			seen[d] = true
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// annotateSource prints lines from..to of src, each followed by its diagnostics.
+func annotateSource(w io.Writer, src []byte, diags []escapeDiagnostic, from, to int) {
+	byLine := map[int][]escapeDiagnostic{}
+	for _, d := range diags {
+		byLine[d.line] = append(byLine[d.line], d)
+	}
+
+	lines := strings.Split(string(src), "\n")
+	if to > len(lines) {
+		to = len(lines)
+	}
+	for n := from; n <= to; n++ {
+		text := lines[n-1]
+		fmt.Fprintf(w, "%4d  %s\n", n, expandTabs(text))
+		ds := byLine[n]
+		sort.SliceStable(ds, func(i, j int) bool { return ds[i].col < ds[j].col })
+		for _, d := range ds {
+			col := d.col - 1
+			if col > len(text) {
+				col = len(text)
+			}
+			fmt.Fprintf(w, "      %s^ %s\n", strings.Repeat(" ", len(expandTabs(text[:col]))), d.msg)
+		}
+	}
+}
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", "    ")
+}
+
+// exampleFuncName returns the name of the function an example runs, e.g. "goRoutineExample".
+func exampleFuncName(ex example) string {
+	name := runtime.FuncForPC(reflect.ValueOf(ex.run).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// findFunc locates a top-level function declaration in the Go files of dir.
+func findFunc(dir, name string) (file string, from, to int, err error) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != name {
+				continue
+			}
+			start := fset.Position(fn.Pos()).Line
+			if fn.Doc != nil {
+				start = fset.Position(fn.Doc.Pos()).Line
+			}
+			return path, start, fset.Position(fn.End()).Line, nil
+		}
+	}
+	return "", 0, 0, fmt.Errorf("function %s not found in %s", name, dir)
+}
+
+func escapeCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("escape", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", ".", "directory of the package to compile")
+	inline := fs.Bool("inline", false, "also show inlining decisions")
+	target, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(target) != 1 {
+		fmt.Fprintln(stderr, "usage: go-concepts escape [-dir dir] [-inline] <file.go | example>")
+		return 2
+	}
+
+	// A file is compiled with the package in its own directory; an example with the
+	// package in -dir.
+	var path string
+	buildDir := *dir
+	from, to := 1, int(^uint(0)>>1)
+	if strings.HasSuffix(target[0], ".go") {
+		path = target[0]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(*dir, path)
+		}
+		buildDir = filepath.Dir(path)
+	} else {
+		ex, ok := lookupExample(target[0])
+		if !ok {
+			fmt.Fprintf(stderr, "escape: %q is neither a .go file nor an example (see go-concepts list)\n", target[0])
+			return 2
+		}
+		path, from, to, err = findFunc(*dir, exampleFuncName(ex))
+		if err != nil {
+			fmt.Fprintln(stderr, "escape:", err)
+			return 1
+		}
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, "escape:", err)
+		return 1
+	}
+	diags, err := runEscapeAnalysis(buildDir, *inline)
+	if err != nil {
+		fmt.Fprintln(stderr, "escape:", err)
+		return 1
+	}
+
+	rel, err := filepath.Rel(buildDir, path)
+	if err != nil {
+		rel = path
+	}
+	var inFile []escapeDiagnostic
+	for _, d := range diags {
+		if d.file == filepath.Clean(rel) {
+			inFile = append(inFile, d)
+		}
+	}
+	fmt.Fprintf(stdout, "%s: %d escape analysis notes\n", rel, len(inFile))
+	annotateSource(stdout, src, inFile, from, to)
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import "fmt"
+
+// Escape analysis decides, at compile time, whether a value can live on the stack.
+/* A value stays on the goroutine's stack when the compiler can prove nothing uses
+it after the function returns. Otherwise it is "moved to heap" and the garbage
+collector has to clean it up. Each function below shows one reason a value escapes.
+Run "go-concepts escape escape_examples.go" to see the compiler's decisions inline.
+
+The same report is available straight from the compiler:
+	go build -gcflags=-m .
+*/
+
+// stackOnly never lets its array leave the function: it stays on the stack.
+func stackOnly() int {
+	var squares [8]int
+	for i := range squares {
+		squares[i] = i * i
+	}
+	total := 0
+	for _, v := range squares {
+		total += v
+	}
+	return total
+}
+
+// newCounter returns a pointer to a local variable, so c outlives the call: moved to heap: c.
+func newCounter() *int {
+	c := 0
+	return &c
+}
+
+// makeAccumulator's closure keeps total alive after it returns: moved to heap: total.
+func makeAccumulator() func(int) int {
+	total := 0
+	return func(n int) int {
+		total += n
+		return total
+	}
+}
+
+// describe passes n to fmt.Sprint, whose ...interface{} parameter escapes:
+// converting n to an interface makes it escape to the heap.
+func describe(n int) string {
+	return fmt.Sprint("value ", n)
+}
+
+// largeBuffer is too big for a stack frame, so make allocates it on the heap.
+func largeBuffer() int {
+	buf := make([]byte, 1<<20)
+	buf[0] = 1
+	return len(buf)
+}
+
+// dynamicSlice's make has a size only known at run time, so the compiler cannot reserve a
+// stack slot for it. Older releases always put it on the heap ("non-constant size"); newer
+// ones use a small stack buffer when the slice does not escape and fall back to the heap.
+func dynamicSlice(n int) int {
+	s := make([]int, n)
+	return len(s)
+}
+
+var keptPointer *int
+
+// keep stores its argument in a global: leaking param: p.
+func keep(p *int) {
+	keptPointer = p
+}
+
+// deref only reads through p, so the caller's value can stay on its stack: p does not escape.
+func deref(p *int) int {
+	return *p
+}
+
+// escapeExample calls each function above.
+func escapeExample(ec *exampleContext) {
+	ec.println("Escape analysis examples")
+	ec.println("stack only:", stackOnly())
+	c := newCounter()
+	*c++
+	ec.println("counter:", *c)
+	acc := makeAccumulator()
+	acc(1)
+	ec.println("accumulator:", acc(2))
+	ec.println("describe:", describe(42))
+	ec.println("large buffer:", largeBuffer())
+	ec.println("dynamic slice:", dynamicSlice(3))
+	n := 7
+	keep(&n)
+	ec.println("deref:", deref(&n))
+	ec.println("Run \"go-concepts escape escape_examples.go\" to see where each value lives")
+}
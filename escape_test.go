@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEscapeOutput(t *testing.T) {
+	out := []byte(`# example
+./escape_examples.go:29:6: can inline newCounter
+./escape_examples.go:30:2: moved to heap: c
+./escape_examples.go:30:2: moved to heap: c
+./goroutine.go:31:14: inlining call to exampleContext.println
+./goroutine.go:23:23: leaking param: ec
+`)
+	got := parseEscapeOutput(out, false)
+	want := []escapeDiagnostic{
+		{"escape_examples.go", 30, 2, "moved to heap: c"},
+		{"goroutine.go", 23, 23, "leaking param: ec"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("diagnostic %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if n := len(parseEscapeOutput(out, true)); n != 4 {
+		t.Errorf("with inlining notes got %d diagnostics, want 4", n)
+	}
+}
+
+func TestAnnotateSource(t *testing.T) {
+	src := []byte("func f() *int {\n\tc := 0\n\treturn &c\n}\n")
+	var buf bytes.Buffer
+	annotateSource(&buf, src, []escapeDiagnostic{{"f.go", 2, 2, "moved to heap: c"}}, 2, 3)
+	want := "   2      c := 0\n" +
+		"          ^ moved to heap: c\n" +
+		"   3      return &c\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+// TestEscapeCommand compiles the package and checks the decisions documented in escape_examples.go.
+func TestEscapeCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the package")
+	}
+	var stdout, stderr bytes.Buffer
+	if code := escapeCommand([]string{"escape_examples.go"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"^ moved to heap: c",
+		"^ moved to heap: total",
+		"^ leaking param: p",
+		"^ p does not escape",
+		"^ n escapes to heap",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := escapeCommand([]string{"goroutines"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "func goRoutineExample(") || strings.Contains(stdout.String(), "package main") {
+		t.Errorf("example report should cover only goRoutineExample:\n%s", stdout.String())
+	}
+}
+
+// TestEscapeCommandFileOutsideDir annotates a file by absolute path in a module of its
+// own, which has to be built in the file's directory rather than in -dir.
+func TestEscapeCommandFileOutsideDir(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles a package")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":  "module escapetest\n\ngo 1.18\n",
+		"main.go": "package main\n\nfunc counter() *int {\n\tn := 0\n\treturn &n\n}\n\nfunc main() { println(*counter()) }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	if code := escapeCommand([]string{filepath.Join(dir, "main.go")}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "^ moved to heap: n") {
+		t.Errorf("report is missing the escape of n:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"text/tabwriter"
+)
+
+// An example is a named, runnable concept.
+/* Every concept in this module is registered here with a short description,
+so it can be run from the command line instead of editing main():
+
+	go-concepts list
+	go-concepts run channels
+	go-concepts run goroutines select
+	go-concepts run --all
+
+Examples run in the order they are listed below.
+*/
+type example struct {
+	name        string
+	description string
+	run         func(ec *exampleContext)
+}
+
+// An exampleContext is what an example runs in.
+/* Examples never print to stdout directly. They write through the context's
+writer and logger, so the same example can feed a terminal, a test buffer or
+any other io.Writer:
+
+	ec := newExampleContext(os.Stdout)
+	channelExample(ec)
+
+	var buf bytes.Buffer
+	channelExample(newExampleContext(&buf))
+
+Examples print from several goroutines at once, so all writes are serialized.
+Examples that sleep or wait use the context's clock, which tests replace with a fake one.
+*/
+type exampleContext struct {
+	out   io.Writer
+	log   *log.Logger
+	clock clock
+	trace *chanTracer     // nil unless channel tracing was asked for
+	ctx   context.Context // carries the runtime/trace task of the running example
+
+	releaseMu sync.Mutex
+	release   func() // unblocks a deadlock example, see onRelease
+}
+
+// newExampleContext returns a context on the real clock that writes to w, with
+// a logger that prefixes lines with the date and time like the standard logger.
+func newExampleContext(w io.Writer) *exampleContext {
+	out := &lockedWriter{w: w}
+	return &exampleContext{
+		out:   out,
+		log:   log.New(out, "", log.LstdFlags),
+		clock: realClock{},
+		ctx:   context.Background(),
+	}
+}
+
+func (ec *exampleContext) println(a ...interface{}) {
+	fmt.Fprintln(ec.out, a...)
+}
+
+func (ec *exampleContext) printf(format string, a ...interface{}) {
+	fmt.Fprintf(ec.out, format, a...)
+}
+
+// lockedWriter serializes writes to w so goroutines can share it.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+var examples = []example{
+	{"channels", "unbuffered channels, buffered channels, select and range/close", channelExample},
+	{"buffered-channel", "send and receive on a buffered channel of capacity 2", bufferedChannel},
+	{"select", "wait on multiple channels with select and a default case", selectExample},
+	{"select-context", "stop selects with context deadlines, timeouts and parent cancellation", selectContextExample},
+	{"range-close", "range over a channel closed by the fibonacci generator", rangeAndCloseChannel},
+	{"pipeline", "chain generator, map, filter, flat-map, batch and take stages with cancellation", pipelineExample},
+	{"slices", "slice headers, shared backing arrays, append reallocation and capacity growth", slicesExample},
+	{"struct-layout", "field offsets, alignment padding and a reordered struct that saves space", structLayoutExample},
+	{"map-internals", "simulated hash map: buckets, tophash, overflow, growth, evacuation and range order", mapInternalsExample},
+	{"iface-internals", "the two words of interface values, itab caching and the typed-nil error pitfall", ifaceInternalsExample},
+	{"method-sets", "value and pointer receivers, the method sets of T and *T and the interfaces they satisfy", methodSetsExample},
+	{"goroutines", "start goroutines for a function and an anonymous function", goRoutineExample},
+	{"sync", "WaitGroup, Mutex, RWMutex, Once, Cond, sync.Map, Pool and sync/atomic", syncExample},
+	{"worker-pool", "CPU-bound and IO-bound jobs on fixed and dynamic worker pools", workerPoolExample},
+	{"parallel-reduce", "generic N-way reduce with one goroutine per chunk, combined in order", parallelReduceExample},
+	{"hchan", "step through a simulated hchan: circular buffer, sendq/recvq and close", hchanExample},
+	{"scheduler", "simulate the G/M/P scheduler on goroutine workloads with 1 and 4 Ps", schedulerExample},
+	{"gc", "tri-color mark and sweep with and without the write barrier", gcExample},
+	{"escape-analysis", "values that stay on the stack and the reasons others move to the heap", escapeExample},
+	{"http-client", "GET/POST JSON, timeouts, transports, retries and streaming against httptest", httpClient},
+}
+
+// lookupExample returns the registered example with the given name.
+func lookupExample(name string) (example, bool) {
+	for _, ex := range examples {
+		if ex.name == name {
+			return ex, true
+		}
+	}
+	return example{}, false
+}
+
+// listExamples writes the name and description of every registered example.
+func listExamples(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, ex := range examples {
+		fmt.Fprintf(tw, "%s\t%s\n", ex.name, ex.description)
+	}
+	fmt.Fprintln(tw, "\nOnly with run --diagnose:")
+	for _, ex := range deadlockExamples {
+		fmt.Fprintf(tw, "%s\t%s\n", ex.name, ex.description)
+	}
+	tw.Flush()
+}
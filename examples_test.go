@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// unorderedExamples print from several goroutines at once, so their lines are
+// compared as a sorted set rather than in order.
+var unorderedExamples = map[string]bool{
+	"goroutines": true,
+}
+
+// documentedOutputs maps a source file to the example its "Output:" block documents.
+var documentedOutputs = map[string]string{
+	"channels.go":    "channels",
+	"goroutine.go":   "goroutines",
+	"http_client.go": "http-client",
+}
+
+var logTimestamp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+
+// timingDependent rewrites lines whose content depends on which goroutine ran first.
+// The two partial sums in channelExample arrive in either order; only their total is fixed.
+// The pointers printed by the slice inspector and the interface words change from run
+// to run; a nil word, 0x0, does not.
+var timingDependent = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`^Values are:  -?\d+ -?\d+ (-?\d+)$`), "Values are:  <x> <y> $1"},
+	{regexp.MustCompile(`\b(ptr|type|data|tab)=0x[0-9a-f]{5,}\b`), "$1=<addr>"},
+}
+
+// normalizeOutput removes the parts of an example's output that change from run to run:
+// log timestamps, timing-dependent values and, for unordered examples, the order of the lines.
+func normalizeOutput(name, out string) string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		line = logTimestamp.ReplaceAllString(strings.TrimRight(line, " \t"), "")
+		if line == "" {
+			continue
+		}
+		for _, td := range timingDependent {
+			line = td.re.ReplaceAllString(line, td.repl)
+		}
+		lines = append(lines, line)
+	}
+	if unorderedExamples[name] {
+		sort.Strings(lines)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// withoutTicks drops the "." lines printed by selectExample's default case.
+// Their number is exact on the fake clock but varies on the real one.
+func withoutTicks(out string) string {
+	var buf strings.Builder
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if strings.TrimSpace(line) != "." {
+			buf.WriteString(line)
+		}
+	}
+	return buf.String()
+}
+
+// captureOutput runs ex on a fake clock and returns everything it wrote through its context.
+func captureOutput(ex example) string {
+	var buf bytes.Buffer
+	ec := newExampleContext(&buf)
+	fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+	ec.clock = fc
+	fc.run(func() { ex.run(ec) })
+	return buf.String()
+}
+
+func goldenPath(name string) string {
+	return filepath.Join("testdata", "golden", name+".golden")
+}
+
+func TestGoldenOutput(t *testing.T) {
+	for _, ex := range examples {
+		ex := ex
+		t.Run(ex.name, func(t *testing.T) {
+			got := normalizeOutput(ex.name, captureOutput(ex))
+
+			path := goldenPath(ex.name)
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("output of %s does not match %s\n--- got:\n%s--- want:\n%s", ex.name, path, got, want)
+			}
+		})
+	}
+}
+
+// TestDocumentedOutput checks that the "Output:" comment blocks in the source
+// files still describe what the examples print.
+func TestDocumentedOutput(t *testing.T) {
+	for file, name := range documentedOutputs {
+		file, name := file, name
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, ok := documentedOutput(string(src))
+			if !ok {
+				t.Fatalf("%s has no /* Output: */ block", file)
+			}
+			want, err := os.ReadFile(goldenPath(name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The documented runs used the real clock, so their ticks are not comparable.
+			if got, want := withoutTicks(normalizeOutput(name, doc)), withoutTicks(string(want)); got != want {
+				t.Errorf("Output block in %s drifted from %s\n--- documented:\n%s--- golden:\n%s", file, goldenPath(name), got, want)
+			}
+		})
+	}
+}
+
+// documentedOutput extracts the body of the "/* Output:" comment in src,
+// without the command line and the runner's own log lines.
+func documentedOutput(src string) (string, bool) {
+	start := strings.Index(src, "/* Output:")
+	if start < 0 {
+		return "", false
+	}
+	body := src[start+len("/* Output:"):]
+	end := strings.Index(body, "*/")
+	if end < 0 {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+	for _, line := range strings.Split(body[:end], "\n") {
+		trimmed := logTimestamp.ReplaceAllString(strings.TrimSpace(line), "")
+		switch {
+		case strings.HasPrefix(trimmed, "%"), strings.HasPrefix(trimmed, "go run"):
+			continue
+		case strings.HasPrefix(trimmed, "Welcome to Go Concepts"), strings.HasPrefix(trimmed, "Running "):
+			continue
+		}
+		buf.WriteString(line + "\n")
+	}
+	return buf.String(), true
+}
+
+// TestExamplesDoNotLeak runs every example and fails if it leaves goroutines behind.
+func TestExamplesDoNotLeak(t *testing.T) {
+	for _, ex := range examples {
+		ex := ex
+		t.Run(ex.name, func(t *testing.T) {
+			before := snapshotGoroutines()
+			captureOutput(ex)
+			if leaks := before.leaked(2 * time.Second); len(leaks) > 0 {
+				t.Error(formatLeaks(leaks))
+			}
+		})
+	}
+}
+
+// TestFakeClockDeterministic runs the timing-dependent examples repeatedly and
+// expects the exact same output, ticks included, every time.
+func TestFakeClockDeterministic(t *testing.T) {
+	for _, name := range []string{"select", "channels"} {
+		ex, _ := lookupExample(name)
+		first := normalizeOutput(name, captureOutput(ex))
+		for i := 0; i < 20; i++ {
+			if got := normalizeOutput(name, captureOutput(ex)); got != first {
+				t.Fatalf("%s run %d differs\n--- got:\n%s--- first:\n%s", name, i, got, first)
+			}
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// A simulator of the tri-color mark and sweep collector described in main.go.
+/* The heap is a graph of objects with two pointer fields each. The mutator holds
+pointers in a few root slots (think stack variables) and changes the graph while
+the collector runs:
+
+white - not reached yet. Whatever is still white when marking ends is freed.
+grey  - reached, but its fields have not been scanned.
+black - reached and scanned. The collector never looks at a black object again.
+
+Mark: shade the objects the roots point to grey, then repeatedly take a grey object,
+shade everything it points to and turn it black. Marking ends when no grey object is left.
+Sweep: free every white object.
+
+Because the mutator runs concurrently, it can break the collector's invariant
+"no black object points to a white object":
+
+1. the collector has scanned A (black); B (grey) still points to C (white)
+2. the mutator copies the pointer to C into A:  A.f1 = B.f0
+3. the mutator deletes the only other path:     B.f0 = nil
+4. the collector scans B and finds nothing. C stays white and is freed while A still points to it.
+
+The write barrier prevents this. Go uses a hybrid barrier that shades both the old
+and the new value of a pointer field on every heap write:
+
+	writePointer(slot, ptr):
+		shade(*slot)  // deletion barrier (Yuasa)
+		shade(ptr)    // insertion barrier (Dijkstra)
+		*slot = ptr
+
+Objects allocated during a cycle are allocated black, and root writes need no barrier.
+
+	go-concepts gcsim                      the lost-object scenario with and without the barrier
+	go-concepts gcsim -random -steps 500   a random mutator interleaved with the collector
+*/
+type gcColor int
+
+const (
+	gcWhite gcColor = iota
+	gcGrey
+	gcBlack
+)
+
+func (c gcColor) String() string {
+	return [...]string{"white", "grey", "black"}[c]
+}
+
+const gcFields = 2
+
+type gcObject struct {
+	id     int
+	name   string
+	fields [gcFields]*gcObject
+	color  gcColor
+	freed  bool
+}
+
+func (o *gcObject) String() string {
+	if o == nil {
+		return "nil"
+	}
+	return o.name
+}
+
+type gcPhase int
+
+const (
+	gcOff gcPhase = iota
+	gcMark
+	gcSweep
+)
+
+// gcHeap is the shared state of the mutator and the collector. Every operation
+// takes the lock, so the two interleave one operation at a time.
+type gcHeap struct {
+	mu      sync.Mutex
+	objects []*gcObject // allocated objects, in allocation order
+	roots   []*gcObject
+	grey    []*gcObject
+	phase   gcPhase
+	cycle   int
+	barrier bool
+	nextID  int
+	log     func(format string, args ...interface{})
+
+	// freedReachable lists objects the collector freed although the roots could still reach them.
+	freedReachable []*gcObject
+	// useAfterFree counts mutator accesses to freed objects.
+	useAfterFree int
+	freed        int
+}
+
+func newGCHeap(roots int, barrier bool) *gcHeap {
+	return &gcHeap{roots: make([]*gcObject, roots), barrier: barrier, log: func(string, ...interface{}) {}}
+}
+
+// alloc creates an object. During a cycle new objects are allocated black.
+func (h *gcHeap) alloc(name string) *gcObject {
+	h.nextID++
+	if name == "" {
+		name = fmt.Sprintf("o%d", h.nextID)
+	}
+	o := &gcObject{id: h.nextID, name: name}
+	if h.phase != gcOff {
+		o.color = gcBlack
+	}
+	h.objects = append(h.objects, o)
+	return o
+}
+
+// shade turns a white object grey and queues it for scanning.
+func (h *gcHeap) shade(o *gcObject) {
+	if o != nil && o.color == gcWhite && h.phase == gcMark {
+		o.color = gcGrey
+		h.grey = append(h.grey, o)
+	}
+}
+
+// writePointer is the mutator's obj.fields[f] = ptr, with the write barrier if enabled.
+func (h *gcHeap) writePointer(obj *gcObject, f int, ptr *gcObject) {
+	if obj.freed || (ptr != nil && ptr.freed) {
+		h.useAfterFree++
+		h.log("mutator: USE AFTER FREE writing %v.f%d = %v", obj, f, ptr)
+	}
+	if h.barrier && h.phase == gcMark {
+		h.shade(obj.fields[f])
+		h.shade(ptr)
+	}
+	h.log("mutator: %v.f%d = %v", obj, f, ptr)
+	obj.fields[f] = ptr
+}
+
+// setRoot is a write to a stack slot: no write barrier.
+func (h *gcHeap) setRoot(r int, ptr *gcObject) {
+	h.log("mutator: root%d = %v", r, ptr)
+	h.roots[r] = ptr
+}
+
+// startCycle whitens every object and shades the roots.
+func (h *gcHeap) startCycle() {
+	h.cycle++
+	h.phase = gcMark
+	h.grey = h.grey[:0]
+	for _, o := range h.objects {
+		o.color = gcWhite
+	}
+	for _, r := range h.roots {
+		h.shade(r)
+	}
+	h.log("collector: cycle %d mark phase starts, roots shaded: %s", h.cycle, h.colors())
+}
+
+// markStep scans one grey object. It reports false when there is nothing left to scan.
+func (h *gcHeap) markStep() bool {
+	if len(h.grey) == 0 {
+		return false
+	}
+	o := h.grey[0]
+	h.grey = h.grey[1:]
+	for _, child := range o.fields {
+		h.shade(child)
+	}
+	o.color = gcBlack
+	h.log("collector: scan %v -> %s", o, h.colors())
+	return true
+}
+
+// sweep frees every white object and ends the cycle.
+func (h *gcHeap) sweep() {
+	h.phase = gcSweep
+	reachable := h.reachable()
+	live := h.objects[:0]
+	var freed []string
+	for _, o := range h.objects {
+		if o.color != gcWhite {
+			live = append(live, o)
+			continue
+		}
+		if reachable[o] {
+			h.freedReachable = append(h.freedReachable, o)
+			h.log("collector: BUG %v is reachable but white, freeing it anyway", o)
+		}
+		o.freed = true
+		h.freed++
+		freed = append(freed, o.name)
+	}
+	h.objects = live
+	if len(freed) == 0 {
+		freed = append(freed, "nothing")
+	}
+	h.log("collector: cycle %d sweep frees %s", h.cycle, strings.Join(freed, ", "))
+	h.phase = gcOff
+}
+
+// reachable is the oracle: every object the roots can reach right now.
+func (h *gcHeap) reachable() map[*gcObject]bool {
+	seen := map[*gcObject]bool{}
+	var visit func(o *gcObject)
+	visit = func(o *gcObject) {
+		if o == nil || seen[o] {
+			return
+		}
+		seen[o] = true
+		for _, child := range o.fields {
+			visit(child)
+		}
+	}
+	for _, r := range h.roots {
+		visit(r)
+	}
+	return seen
+}
+
+// colors lists the objects by color, e.g. "white[C] grey[B] black[A]".
+func (h *gcHeap) colors() string {
+	byColor := map[gcColor][]string{}
+	for _, o := range h.objects {
+		byColor[o.color] = append(byColor[o.color], o.name)
+	}
+	var parts []string
+	for _, c := range []gcColor{gcWhite, gcGrey, gcBlack} {
+		parts = append(parts, fmt.Sprintf("%v[%s]", c, strings.Join(byColor[c], " ")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// gcLostObjectScenario replays the interleaving from the comment above and
+// returns the heap after the cycle, so the caller can see whether C survived.
+func gcLostObjectScenario(barrier bool, log func(string, ...interface{})) *gcHeap {
+	h := newGCHeap(1, barrier)
+	h.log = log
+	a, b, c := h.alloc("A"), h.alloc("B"), h.alloc("C")
+	h.setRoot(0, a)
+	h.writePointer(a, 0, b)
+	h.writePointer(b, 0, c)
+
+	h.startCycle()
+	h.markStep() // scan A: A black, B grey, C white
+	h.writePointer(a, 1, b.fields[0])
+	h.writePointer(b, 0, nil)
+	for h.markStep() {
+	}
+	h.sweep()
+	return h
+}
+
+// gcMutator makes random changes to the heap, using only pointers it can reach from its roots.
+type gcMutator struct {
+	h   *gcHeap
+	rng *rand.Rand
+}
+
+// pick follows a random path from a random root, or returns nil.
+func (m *gcMutator) pick() *gcObject {
+	o := m.h.roots[m.rng.Intn(len(m.h.roots))]
+	for o != nil && m.rng.Intn(3) > 0 {
+		next := o.fields[m.rng.Intn(gcFields)]
+		if next == nil {
+			break
+		}
+		o = next
+	}
+	return o
+}
+
+// step performs one random mutation. m.h.mu must be held.
+func (m *gcMutator) step() {
+	h := m.h
+	switch m.rng.Intn(10) {
+	case 0:
+		h.setRoot(m.rng.Intn(len(h.roots)), h.alloc(""))
+	case 1, 2:
+		if obj := m.pick(); obj != nil {
+			h.writePointer(obj, m.rng.Intn(gcFields), h.alloc(""))
+		} else {
+			h.setRoot(m.rng.Intn(len(h.roots)), h.alloc(""))
+		}
+	case 3, 4, 5:
+		if obj := m.pick(); obj != nil {
+			h.writePointer(obj, m.rng.Intn(gcFields), m.pick())
+		}
+	case 6, 7:
+		if obj := m.pick(); obj != nil {
+			h.setRoot(m.rng.Intn(len(h.roots)), obj.fields[m.rng.Intn(gcFields)])
+		}
+	case 8:
+		if obj := m.pick(); obj != nil {
+			h.writePointer(obj, m.rng.Intn(gcFields), nil)
+		}
+	default:
+		if m.rng.Intn(3) == 0 {
+			h.setRoot(m.rng.Intn(len(h.roots)), nil)
+		}
+	}
+}
+
+// gcSimResult summarizes a random simulation.
+type gcSimResult struct {
+	cycles         int
+	freed          int
+	live           int
+	freedReachable int
+	useAfterFree   int
+}
+
+// runGCSimulation runs a random mutator against repeated collection cycles for
+// the given number of mutator steps. If concurrent, the mutator and the collector
+// are separate goroutines; otherwise a seeded coin decides who runs next.
+func runGCSimulation(seed int64, steps int, barrier, concurrent bool, log func(string, ...interface{})) gcSimResult {
+	h := newGCHeap(4, barrier)
+	if log != nil {
+		h.log = log
+	}
+	mut := &gcMutator{h: h, rng: rand.New(rand.NewSource(seed))}
+
+	// collectorStep advances the collector by one unit of work. h.mu must be held.
+	collectorStep := func() {
+		switch h.phase {
+		case gcOff:
+			h.startCycle()
+		case gcMark:
+			if !h.markStep() {
+				h.sweep()
+			}
+		}
+	}
+
+	if concurrent {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < steps; i++ {
+				h.mu.Lock()
+				mut.step()
+				h.mu.Unlock()
+				runtime.Gosched()
+			}
+		}()
+		for running := true; running; {
+			select {
+			case <-done:
+				running = false
+			default:
+				h.mu.Lock()
+				collectorStep()
+				h.mu.Unlock()
+				runtime.Gosched()
+			}
+		}
+	} else {
+		coin := rand.New(rand.NewSource(seed + 1))
+		for i := 0; i < steps; {
+			if coin.Intn(2) == 0 {
+				mut.step()
+				i++
+			} else {
+				collectorStep()
+			}
+		}
+	}
+
+	// Finish the cycle in progress, then run a full one with the mutator stopped.
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for h.phase != gcOff {
+		collectorStep()
+	}
+	h.startCycle()
+	for h.markStep() {
+	}
+	h.sweep()
+
+	return gcSimResult{cycles: h.cycle, freed: h.freed, live: len(h.objects),
+		freedReachable: len(h.freedReachable), useAfterFree: h.useAfterFree}
+}
+
+// gcExample shows the lost object without a write barrier, and the barrier saving it.
+func gcExample(ec *exampleContext) {
+	logf := func(format string, args ...interface{}) { ec.printf("  "+format+"\n", args...) }
+	for _, barrier := range []bool{false, true} {
+		ec.printf("Tri-color mark and sweep, write barrier %s\n", onOff(barrier))
+		h := gcLostObjectScenario(barrier, logf)
+		if len(h.freedReachable) > 0 {
+			ec.printf("Result: %v was freed while A.f1 still points to it\n", h.freedReachable[0])
+		} else {
+			ec.println("Result: every reachable object survived")
+		}
+	}
+
+	r := runGCSimulation(1, 2000, true, false, nil)
+	ec.printf("Random mutator, 2000 steps, barrier on: %d cycles, %d objects freed, %d live, %d reachable objects freed\n",
+		r.cycles, r.freed, r.live, r.freedReachable)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func gcsimCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gcsim", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	barrier := fs.Bool("barrier", true, "enable the write barrier")
+	random := fs.Bool("random", false, "run a random mutator instead of the lost-object scenario")
+	steps := fs.Int("steps", 200, "mutator steps for -random")
+	seed := fs.Int64("seed", 1, "random seed for -random")
+	concurrent := fs.Bool("concurrent", false, "run the mutator and collector as separate goroutines")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	logf := func(format string, args ...interface{}) { fmt.Fprintf(stdout, format+"\n", args...) }
+
+	if !*random {
+		h := gcLostObjectScenario(*barrier, logf)
+		if len(h.freedReachable) > 0 {
+			fmt.Fprintf(stdout, "%d reachable objects freed\n", len(h.freedReachable))
+			return 1
+		}
+		return 0
+	}
+
+	r := runGCSimulation(*seed, *steps, *barrier, *concurrent, logf)
+	fmt.Fprintf(stdout, "%d cycles, %d freed, %d live, %d reachable objects freed, %d uses after free\n",
+		r.cycles, r.freed, r.live, r.freedReachable, r.useAfterFree)
+	if r.freedReachable > 0 {
+		return 1
+	}
+	return 0
+}
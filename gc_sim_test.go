@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestGCLostObjectScenario(t *testing.T) {
+	h := gcLostObjectScenario(false, t.Logf)
+	if len(h.freedReachable) != 1 || h.freedReachable[0].name != "C" {
+		t.Errorf("without the barrier freed reachable %v, want [C]", h.freedReachable)
+	}
+
+	h = gcLostObjectScenario(true, t.Logf)
+	if len(h.freedReachable) != 0 {
+		t.Errorf("with the barrier freed reachable %v", h.freedReachable)
+	}
+	if c := h.roots[0].fields[1]; c == nil || c.name != "C" || c.freed {
+		t.Errorf("A.f1 = %v, want a live C", c)
+	}
+}
+
+// TestGCNeverFreesReachableObjects runs random mutators against the collector and
+// checks, at every sweep, that no object reachable from the roots is freed.
+func TestGCNeverFreesReachableObjects(t *testing.T) {
+	for seed := int64(1); seed <= 50; seed++ {
+		r := runGCSimulation(seed, 2000, true, false, nil)
+		if r.freedReachable != 0 || r.useAfterFree != 0 {
+			t.Errorf("seed %d: %d reachable objects freed, %d uses after free", seed, r.freedReachable, r.useAfterFree)
+		}
+		if r.cycles == 0 || r.freed == 0 {
+			t.Errorf("seed %d: %d cycles freed %d objects, want some garbage collected", seed, r.cycles, r.freed)
+		}
+	}
+}
+
+func TestGCConcurrentMutatorNeverLosesObjects(t *testing.T) {
+	for seed := int64(1); seed <= 10; seed++ {
+		r := runGCSimulation(seed, 5000, true, true, nil)
+		if r.freedReachable != 0 || r.useAfterFree != 0 {
+			t.Errorf("seed %d: %d reachable objects freed, %d uses after free", seed, r.freedReachable, r.useAfterFree)
+		}
+	}
+}
+
+// TestGCWithoutBarrierLosesObjects makes sure the oracle can actually catch the bug.
+func TestGCWithoutBarrierLosesObjects(t *testing.T) {
+	lost := 0
+	for seed := int64(1); seed <= 20; seed++ {
+		lost += runGCSimulation(seed, 3000, false, false, nil).freedReachable
+	}
+	if lost == 0 {
+		t.Error("20 random runs without the write barrier never freed a reachable object")
+	}
+}
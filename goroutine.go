@@ -1,9 +1,7 @@
 package main
 
 import (
-	"fmt"
-	"log"
-	"time"
+	"sync"
 )
 
 // A goroutine is a lightweight thread managed by the Go runtime.
@@ -15,26 +13,36 @@ The evaluation of f and parameters(s here) happens in the current goroutine and
 
 Goroutines run in the same address space, so access to shared memory must be synchronized.
 The sync package provides useful primitives, although you won't need them much in Go as there are other primitives.
+
+main does not wait for the goroutines it starts: when it returns the program exits, finished or not.
+goRoutineExample waits with a sync.WaitGroup rather than sleeping and hoping they are done by then.
+sync_examples.go covers the rest of the sync package.
 */
-func routineExample(msg string) {
+func routineExample(ec *exampleContext, msg string) {
 	for i := 0; i < 3; i++ {
-		fmt.Println(msg, " : ", i)
+		ec.println(msg, " : ", i)
 	}
 }
 
-func goRoutineExample() {
-	log.Println("Start")
+func goRoutineExample(ec *exampleContext) {
+	ec.log.Println("Start")
 
-	routineExample("func")
+	routineExample(ec, "func")
 
-	go routineExample("routine")
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		routineExample(ec, "routine")
+	}()
 
 	go func(msg string) {
-		fmt.Println(msg)
+		defer wg.Done()
+		ec.println(msg)
 	}("another routine")
 
-	time.Sleep(time.Second * 2)
-	log.Println("Done")
+	wg.Wait()
+	ec.log.Println("Done")
 }
 
 /* Output:
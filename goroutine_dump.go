@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runtime.Stack(buf, true) dumps every goroutine of the program, the same text a panic prints.
+/* Each goroutine starts with a header line holding its ID and what it is doing, followed by its stack:
+
+goroutine 7 [chan send]:
+main.selectExample.func2()
+	/src/channels.go:99 +0x4d
+created by main.selectExample in goroutine 1
+	/src/channels.go:97 +0x13d
+
+The state in brackets is "running" or "runnable" for goroutines that can make progress,
+and a wait reason such as "chan send", "chan receive", "select" or "sleep" for blocked ones.
+A long wait also carries its duration, e.g. [chan receive, 2 minutes].
+*/
+type goroutineInfo struct {
+	id    int
+	state string
+	stack string
+}
+
+// blocked reports whether the goroutine is parked waiting for something.
+// A goroutine inside a system call counts as busy: it returns on its own.
+func (g goroutineInfo) blocked() bool {
+	for _, busy := range []string{"running", "runnable", "syscall", "copystack", "preempted"} {
+		if strings.HasPrefix(g.state, busy) {
+			return false
+		}
+	}
+	return true
+}
+
+// dumpGoroutines returns the stacks of all goroutines, as runtime.Stack formats them.
+func dumpGoroutines() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// parseGoroutines splits a goroutine dump into one goroutineInfo per goroutine.
+func parseGoroutines(dump []byte) []goroutineInfo {
+	var gs []goroutineInfo
+	for _, block := range bytes.Split(bytes.TrimSpace(dump), []byte("\n\n")) {
+		header, stack, _ := strings.Cut(string(block), "\n")
+		if !strings.HasPrefix(header, "goroutine ") {
+			continue
+		}
+		idText, rest, ok := strings.Cut(strings.TrimPrefix(header, "goroutine "), " [")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(idText)
+		if err != nil {
+			continue
+		}
+		state := strings.TrimSuffix(rest, "]:")
+		// Drop the wait duration and "locked to thread" annotations.
+		if i := strings.Index(state, ", "); i >= 0 {
+			state = state[:i]
+		}
+		gs = append(gs, goroutineInfo{id: id, state: state, stack: stack})
+	}
+	return gs
+}
+
+// currentGoroutineID returns the ID of the calling goroutine.
+func currentGoroutineID() int {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.Atoi(string(buf))
+	return id
+}
+
+// waitForIdle blocks until every goroutine other than the caller is blocked,
+// or until timeout. It reports whether the program went idle.
+func waitForIdle(timeout time.Duration) bool {
+	self := currentGoroutineID()
+	deadline := time.Now().Add(timeout)
+	// Require two idle dumps in a row: a goroutine that was just woken may
+	// still show as blocked for a moment before it is marked runnable.
+	idleDumps := 0
+	for {
+		idle := true
+		for _, g := range parseGoroutines(dumpGoroutines()) {
+			if g.id != self && !g.blocked() {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			idleDumps++
+			if idleDumps == 2 {
+				return true
+			}
+		} else {
+			idleDumps = 0
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		runtime.Gosched()
+		time.Sleep(50 * time.Microsecond)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// simChan is a user-space copy of the runtime's hchan, described at the end of channels.go.
+/* It keeps the same fields and follows the same algorithms as runtime/chan.go,
+with a mutex standing in for the runtime lock and a channel standing in for gopark/goready:
+
+type simChan[T any] struct {
+	qcount   uint        // total data in the queue
+	dataqsiz uint        // size of the circular queue
+	buf      []T         // the circular queue
+	closed   bool
+	sendx    uint        // send index
+	recvx    uint        // receive index
+	recvq    waitq[T]    // list of blocked receivers
+	sendq    waitq[T]    // list of blocked senders
+	lock     sync.Mutex
+}
+
+send:
+1. If a receiver is parked on recvq, copy the value straight into its sudog and wake it. The buffer is never touched.
+2. Otherwise, if the buffer has room, store the value at buf[sendx] and advance sendx.
+3. Otherwise, park the sender on sendq with a pointer to the value it wants to send.
+
+receive:
+1. If a sender is parked on sendq, the buffer must be full (or the channel unbuffered).
+   Take the value at buf[recvx], put the parked sender's value in the freed slot and wake it.
+   For an unbuffered channel, copy the value straight from the sender.
+2. Otherwise, if the buffer has data, take buf[recvx] and advance recvx.
+3. Otherwise, park the receiver on recvq.
+
+close wakes every parked receiver with the zero value and ok == false,
+and every parked sender, which then panics with "send on closed channel".
+
+Every change of state is reported to the onStep hook with a snapshot, so it can be printed or compared.
+*/
+type simChan[T any] struct {
+	qcount   uint
+	dataqsiz uint
+	buf      []T
+	closed   bool
+	sendx    uint
+	recvx    uint
+	recvq    waitq[T]
+	sendq    waitq[T]
+	lock     sync.Mutex
+
+	// changed is broadcast after every step, for callers waiting on a state.
+	changed *sync.Cond
+	onStep  func(hchanStep[T])
+}
+
+type waitq[T any] struct {
+	first *sudog[T]
+	last  *sudog[T]
+}
+
+// sudog is a goroutine parked on a channel.
+type sudog[T any] struct {
+	g       string // name of the parked goroutine
+	elem    *T     // value to send, or where to store the received value
+	success bool   // woken by a channel operation rather than by close
+	next    *sudog[T]
+	prev    *sudog[T]
+	c       *simChan[T]
+	ready   chan struct{} // closed by goready
+}
+
+// hchanStep is one change of a simChan's state.
+type hchanStep[T any] struct {
+	op    string // "send", "recv" or "close"
+	g     string
+	note  string
+	state hchanState[T]
+}
+
+// hchanState is a snapshot of a simChan's fields.
+type hchanState[T any] struct {
+	buf      []T
+	occupied []bool
+	qcount   uint
+	dataqsiz uint
+	sendx    uint
+	recvx    uint
+	closed   bool
+	sendq    []parkedG[T]
+	recvq    []parkedG[T]
+}
+
+type parkedG[T any] struct {
+	g    string
+	elem T
+}
+
+func newSimChan[T any](size uint) *simChan[T] {
+	c := &simChan[T]{dataqsiz: size, buf: make([]T, size)}
+	c.changed = sync.NewCond(&c.lock)
+	return c
+}
+
+func (q *waitq[T]) enqueue(sg *sudog[T]) {
+	sg.next = nil
+	sg.prev = q.last
+	if q.last == nil {
+		q.first = sg
+	} else {
+		q.last.next = sg
+	}
+	q.last = sg
+}
+
+func (q *waitq[T]) dequeue() *sudog[T] {
+	sg := q.first
+	if sg == nil {
+		return nil
+	}
+	q.first = sg.next
+	if q.first == nil {
+		q.last = nil
+	} else {
+		q.first.prev = nil
+	}
+	sg.next, sg.prev = nil, nil
+	return sg
+}
+
+// goready makes a parked goroutine runnable again.
+func goready[T any](sg *sudog[T]) {
+	close(sg.ready)
+}
+
+// step reports a change of state. c.lock must be held.
+func (c *simChan[T]) step(op, g, format string, args ...interface{}) {
+	if c.onStep != nil {
+		c.onStep(hchanStep[T]{op: op, g: g, note: fmt.Sprintf(format, args...), state: c.snapshot()})
+	}
+	c.changed.Broadcast()
+}
+
+// send is ch <- v executed by goroutine g.
+func (c *simChan[T]) send(g string, v T) {
+	c.chansend(g, v, true)
+}
+
+// trySend is a select with a send case and a default case. It reports whether v was sent.
+func (c *simChan[T]) trySend(g string, v T) bool {
+	return c.chansend(g, v, false)
+}
+
+func (c *simChan[T]) chansend(g string, v T, block bool) bool {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		panic("send on closed channel")
+	}
+
+	if sg := c.recvq.dequeue(); sg != nil {
+		*sg.elem = v
+		sg.success = true
+		goready(sg)
+		c.step("send", g, "copied %v directly to parked receiver %s", v, sg.g)
+		c.lock.Unlock()
+		return true
+	}
+
+	if c.qcount < c.dataqsiz {
+		i := c.sendx
+		c.buf[i] = v
+		c.sendx++
+		if c.sendx == c.dataqsiz {
+			c.sendx = 0
+		}
+		c.qcount++
+		c.step("send", g, "stored %v at buf[%d]", v, i)
+		c.lock.Unlock()
+		return true
+	}
+
+	if !block {
+		c.lock.Unlock()
+		return false
+	}
+
+	sg := &sudog[T]{g: g, elem: &v, c: c, ready: make(chan struct{})}
+	c.sendq.enqueue(sg)
+	if c.dataqsiz == 0 {
+		c.step("send", g, "no receiver waiting, %s parked on sendq with %v", g, v)
+	} else {
+		c.step("send", g, "buffer full, %s parked on sendq with %v", g, v)
+	}
+	c.lock.Unlock()
+
+	<-sg.ready
+	if !sg.success {
+		panic("send on closed channel")
+	}
+	return true
+}
+
+// recv is v, ok := <-ch executed by goroutine g.
+func (c *simChan[T]) recv(g string) (T, bool) {
+	v, ok, _ := c.chanrecv(g, true)
+	return v, ok
+}
+
+// tryRecv is a select with a receive case and a default case.
+// selected reports whether the receive case was chosen.
+func (c *simChan[T]) tryRecv(g string) (v T, ok, selected bool) {
+	return c.chanrecv(g, false)
+}
+
+func (c *simChan[T]) chanrecv(g string, block bool) (v T, ok, selected bool) {
+	var zero T
+	c.lock.Lock()
+
+	if c.closed && c.qcount == 0 {
+		c.step("recv", g, "channel closed and empty, received zero value")
+		c.lock.Unlock()
+		return zero, false, true
+	}
+
+	if sg := c.sendq.dequeue(); sg != nil {
+		if c.dataqsiz == 0 {
+			v = *sg.elem
+			c.wakeSender(sg)
+			c.step("recv", g, "copied %v directly from parked sender %s", v, sg.g)
+		} else {
+			// The buffer is full. Take the head and let the sender fill the freed slot.
+			i := c.recvx
+			v = c.buf[i]
+			c.buf[i] = *sg.elem
+			c.recvx++
+			if c.recvx == c.dataqsiz {
+				c.recvx = 0
+			}
+			c.sendx = c.recvx
+			c.wakeSender(sg)
+			c.step("recv", g, "took %v from buf[%d], moved %v from parked sender %s into it", v, i, *sg.elem, sg.g)
+		}
+		c.lock.Unlock()
+		return v, true, true
+	}
+
+	if c.qcount > 0 {
+		i := c.recvx
+		v = c.buf[i]
+		c.buf[i] = zero
+		c.recvx++
+		if c.recvx == c.dataqsiz {
+			c.recvx = 0
+		}
+		c.qcount--
+		c.step("recv", g, "took %v from buf[%d]", v, i)
+		c.lock.Unlock()
+		return v, true, true
+	}
+
+	if !block {
+		c.lock.Unlock()
+		return zero, false, false
+	}
+
+	sg := &sudog[T]{g: g, elem: &v, c: c, ready: make(chan struct{})}
+	c.recvq.enqueue(sg)
+	if c.dataqsiz == 0 {
+		c.step("recv", g, "no sender waiting, %s parked on recvq", g)
+	} else {
+		c.step("recv", g, "buffer empty, %s parked on recvq", g)
+	}
+	c.lock.Unlock()
+
+	<-sg.ready
+	return v, sg.success, true
+}
+
+// wakeSender wakes a parked sender whose value has been taken. c.lock must be held.
+func (c *simChan[T]) wakeSender(sg *sudog[T]) {
+	sg.success = true
+	goready(sg)
+}
+
+// close is close(ch) executed by goroutine g.
+func (c *simChan[T]) close(g string) {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		panic("close of closed channel")
+	}
+	c.closed = true
+
+	var woken []string
+	var zero T
+	for sg := c.recvq.dequeue(); sg != nil; sg = c.recvq.dequeue() {
+		*sg.elem = zero
+		sg.success = false
+		goready(sg)
+		woken = append(woken, sg.g)
+	}
+	for sg := c.sendq.dequeue(); sg != nil; sg = c.sendq.dequeue() {
+		sg.success = false
+		goready(sg)
+		woken = append(woken, sg.g)
+	}
+
+	if len(woken) == 0 {
+		c.step("close", g, "closed")
+	} else {
+		c.step("close", g, "closed, woke %s", strings.Join(woken, ", "))
+	}
+	c.lock.Unlock()
+}
+
+// len and cap mirror the builtins.
+func (c *simChan[T]) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return int(c.qcount)
+}
+
+func (c *simChan[T]) cap() int {
+	return int(c.dataqsiz)
+}
+
+// state returns a snapshot of the channel's fields.
+func (c *simChan[T]) state() hchanState[T] {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.snapshot()
+}
+
+// waitFor blocks until cond holds for the channel's state, e.g. until a goroutine has parked.
+func (c *simChan[T]) waitFor(cond func(hchanState[T]) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for !cond(c.snapshot()) {
+		c.changed.Wait()
+	}
+}
+
+// snapshot copies the channel's fields. c.lock must be held.
+func (c *simChan[T]) snapshot() hchanState[T] {
+	st := hchanState[T]{
+		buf:      append([]T(nil), c.buf...),
+		occupied: make([]bool, c.dataqsiz),
+		qcount:   c.qcount,
+		dataqsiz: c.dataqsiz,
+		sendx:    c.sendx,
+		recvx:    c.recvx,
+		closed:   c.closed,
+	}
+	for i := uint(0); i < c.qcount; i++ {
+		st.occupied[(c.recvx+i)%c.dataqsiz] = true
+	}
+	for sg := c.sendq.first; sg != nil; sg = sg.next {
+		st.sendq = append(st.sendq, parkedG[T]{g: sg.g, elem: *sg.elem})
+	}
+	for sg := c.recvq.first; sg != nil; sg = sg.next {
+		st.recvq = append(st.recvq, parkedG[T]{g: sg.g})
+	}
+	return st
+}
+
+func (st hchanState[T]) String() string {
+	slots := make([]string, len(st.buf))
+	for i, v := range st.buf {
+		slots[i] = "_"
+		if st.occupied[i] {
+			slots[i] = fmt.Sprint(v)
+		}
+	}
+	var sendq, recvq []string
+	for _, p := range st.sendq {
+		sendq = append(sendq, fmt.Sprintf("%s(%v)", p.g, p.elem))
+	}
+	for _, p := range st.recvq {
+		recvq = append(recvq, p.g)
+	}
+	return fmt.Sprintf("buf=[%s] qcount=%d dataqsiz=%d sendx=%d recvx=%d closed=%t sendq=[%s] recvq=[%s]",
+		strings.Join(slots, " "), st.qcount, st.dataqsiz, st.sendx, st.recvx, st.closed,
+		strings.Join(sendq, " "), strings.Join(recvq, " "))
+}
+
+// hchanExample walks through the scenarios described in channels.go on a simulated
+// make(chan int, 3), printing the hchan fields after every step.
+func hchanExample(ec *exampleContext) {
+	ec.println("hchan simulator: ch := make(chan int, 3)")
+	ch := newSimChan[int](3)
+	ch.onStep = func(s hchanStep[int]) {
+		ec.printf("%-5s %-5s %s\n      %v\n", s.g, s.op, s.note, s.state)
+	}
+
+	ec.println("G1 sends three elements, G2 receives them")
+	for i := 1; i <= 3; i++ {
+		ch.send("G1", i)
+	}
+	for i := 1; i <= 3; i++ {
+		ch.recv("G2")
+	}
+
+	ec.println("G2 receives from the empty channel and parks; G1's send is copied straight to it")
+	got := make(chan int)
+	go func() {
+		v, _ := ch.recv("G2")
+		got <- v
+	}()
+	ch.waitFor(func(st hchanState[int]) bool { return len(st.recvq) == 1 })
+	ch.send("G1", 4)
+	ec.println("G2 received", <-got)
+
+	ec.println("G1 fills the channel and parks on the fourth send; G2's receive makes room")
+	for i := 5; i <= 7; i++ {
+		ch.send("G1", i)
+	}
+	sent := make(chan struct{})
+	go func() {
+		ch.send("G1", 8)
+		close(sent)
+	}()
+	ch.waitFor(func(st hchanState[int]) bool { return len(st.sendq) == 1 })
+	ch.recv("G2")
+	<-sent
+
+	ec.println("G1 closes the channel; G2 drains it, then receives the zero value")
+	ch.close("G1")
+	for {
+		if _, ok := ch.recv("G2"); !ok {
+			break
+		}
+	}
+}
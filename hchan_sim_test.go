@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestSimChanMatchesRealChan applies the same random non-blocking operations to a
+// simChan and a real channel and expects the same results after every step.
+func TestSimChanMatchesRealChan(t *testing.T) {
+	for _, size := range []uint{0, 1, 3, 8} {
+		rng := rand.New(rand.NewSource(int64(size)))
+		sim := newSimChan[int](size)
+		realCh := make(chan int, size)
+
+		for i := 0; i < 1000; i++ {
+			if rng.Intn(2) == 0 {
+				simOK := sim.trySend("G", i)
+				var realOK bool
+				select {
+				case realCh <- i:
+					realOK = true
+				default:
+				}
+				if simOK != realOK {
+					t.Fatalf("cap %d step %d: trySend(%d) = %t, real channel %t", size, i, i, simOK, realOK)
+				}
+			} else {
+				simV, simOK, simSel := sim.tryRecv("G")
+				var realV int
+				var realOK, realSel bool
+				select {
+				case realV, realOK = <-realCh:
+					realSel = true
+				default:
+				}
+				if simV != realV || simOK != realOK || simSel != realSel {
+					t.Fatalf("cap %d step %d: tryRecv = (%d, %t, %t), real channel (%d, %t, %t)",
+						size, i, simV, simOK, simSel, realV, realOK, realSel)
+				}
+			}
+			if sim.len() != len(realCh) || sim.cap() != cap(realCh) {
+				t.Fatalf("cap %d step %d: len/cap = %d/%d, real channel %d/%d", size, i, sim.len(), sim.cap(), len(realCh), cap(realCh))
+			}
+		}
+	}
+}
+
+func TestSimChanCircularIndices(t *testing.T) {
+	ch := newSimChan[int](3)
+	for i := 1; i <= 3; i++ {
+		ch.send("G1", i)
+	}
+	ch.recv("G2")
+	ch.send("G1", 4)
+
+	st := ch.state()
+	if st.sendx != 1 || st.recvx != 1 || st.qcount != 3 {
+		t.Fatalf("after wrap-around: %v, want sendx=1 recvx=1 qcount=3", st)
+	}
+	for want := 2; want <= 4; want++ {
+		if v, ok := ch.recv("G2"); v != want || !ok {
+			t.Fatalf("recv = (%d, %t), want (%d, true)", v, ok, want)
+		}
+	}
+}
+
+func TestSimChanDirectHandoffToParkedReceiver(t *testing.T) {
+	ch := newSimChan[string](0)
+	got := make(chan string)
+	go func() {
+		v, _ := ch.recv("G2")
+		got <- v
+	}()
+	ch.waitFor(func(st hchanState[string]) bool { return len(st.recvq) == 1 })
+
+	var steps []hchanStep[string]
+	ch.onStep = func(s hchanStep[string]) { steps = append(steps, s) }
+	ch.send("G1", "test chan")
+
+	if v := <-got; v != "test chan" {
+		t.Fatalf("receiver got %q", v)
+	}
+	if len(steps) != 1 || steps[0].note != "copied test chan directly to parked receiver G2" {
+		t.Fatalf("steps = %+v, want one direct copy", steps)
+	}
+	if st := steps[0].state; st.qcount != 0 || len(st.recvq) != 0 {
+		t.Errorf("state after handoff = %v, want an empty buffer and recvq", st)
+	}
+}
+
+func TestSimChanParkedSenderFillsFreedSlot(t *testing.T) {
+	ch := newSimChan[int](2)
+	ch.send("G1", 1)
+	ch.send("G1", 2)
+
+	sent := make(chan struct{})
+	go func() {
+		ch.send("G3", 3)
+		close(sent)
+	}()
+	ch.waitFor(func(st hchanState[int]) bool { return len(st.sendq) == 1 })
+	if st := ch.state(); st.sendq[0].g != "G3" || st.sendq[0].elem != 3 {
+		t.Fatalf("sendq = %+v, want G3 waiting to send 3", st.sendq)
+	}
+
+	if v, _ := ch.recv("G2"); v != 1 {
+		t.Fatalf("recv = %d, want 1", v)
+	}
+	<-sent
+	if st := ch.state(); st.qcount != 2 || st.buf[0] != 3 || st.sendx != st.recvx {
+		t.Fatalf("state = %v, want the sender's 3 in the freed slot", st)
+	}
+}
+
+func TestSimChanClose(t *testing.T) {
+	ch := newSimChan[int](1)
+	woken := make(chan bool)
+	go func() {
+		_, ok := ch.recv("G2")
+		woken <- ok
+	}()
+	ch.waitFor(func(st hchanState[int]) bool { return len(st.recvq) == 1 })
+	ch.close("G1")
+	if ok := <-woken; ok {
+		t.Error("parked receiver woken by close got ok = true")
+	}
+	if v, ok := ch.recv("G2"); v != 0 || ok {
+		t.Errorf("recv on closed channel = (%d, %t), want (0, false)", v, ok)
+	}
+
+	expectPanic(t, "send on closed channel", func() { ch.send("G1", 1) })
+	expectPanic(t, "close of closed channel", func() { ch.close("G1") })
+}
+
+func TestSimChanCloseWakesParkedSenderWithPanic(t *testing.T) {
+	ch := newSimChan[int](0)
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		ch.send("G1", 1)
+	}()
+	ch.waitFor(func(st hchanState[int]) bool { return len(st.sendq) == 1 })
+	ch.close("G2")
+	if p := <-panicked; p != "send on closed channel" {
+		t.Fatalf("parked sender recovered %v, want send on closed channel", p)
+	}
+}
+
+func expectPanic(t *testing.T, want string, f func()) {
+	t.Helper()
+	defer func() {
+		if p := recover(); p != want {
+			t.Errorf("recovered %v, want %q", p, want)
+		}
+	}()
+	f()
+}
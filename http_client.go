@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The net/http package provides an HTTP client and server, so web services need no third-party libraries.
+/* A Client sends Requests and returns Responses. The zero value http.DefaultClient works,
+but it has no timeout, so a slow server can hang the caller forever. Create your own client instead:
+
+client := &http.Client{Timeout: 5 * time.Second}
+resp, err := client.Get(url)
+if err != nil {
+	return err
+}
+defer resp.Body.Close()
+
+The caller must always close the response body, otherwise the underlying TCP connection cannot be reused.
+
+A Client is built on a Transport (an http.RoundTripper). The transport owns the connection pool,
+keep-alives, TLS and proxy settings. Wrapping a RoundTripper is the idiomatic way to add
+cross-cutting behaviour such as headers, logging or metrics to every request.
+
+The httptest package starts a real server on a loopback port, so the example below runs offline.
+*/
+func httpClient(ec *exampleContext) {
+	ec.println("HTTP Client Example")
+	srv := httptest.NewServer(newUserServer())
+	defer srv.Close()
+
+	client := &http.Client{Timeout: time.Second}
+
+	httpGetJSON(ec, client, srv.URL)
+	httpPostJSON(ec, client, srv.URL)
+	httpTimeout(ec, srv.URL)
+	httpCustomTransport(ec, srv.URL)
+	httpRetry(ec, client, srv.URL)
+	httpStreaming(ec, client, srv.URL)
+}
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// newUserServer returns the handler used by the client examples.
+func newUserServer() http.Handler {
+	var flakyCalls int32
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user{ID: 1, Name: "Gopher"})
+	})
+
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var u user
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		u.ID = 2
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(u)
+	})
+
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			fmt.Fprintln(w, "finally")
+		case <-r.Context().Done():
+		}
+	})
+
+	mux.HandleFunc("/headers", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, r.Header.Get("X-Example"))
+	})
+
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&flakyCalls, 1) < 3 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		flusher, _ := w.(http.Flusher)
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "event %d\n", i)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	})
+
+	return mux
+}
+
+// GET a resource and decode the JSON body into a struct.
+func httpGetJSON(ec *exampleContext, client *http.Client, baseURL string) {
+	resp, err := client.Get(baseURL + "/users/1")
+	if err != nil {
+		ec.println("GET failed:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var u user
+	if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+		ec.println("decode failed:", err)
+		return
+	}
+	ec.println("GET", resp.StatusCode, u.ID, u.Name)
+}
+
+// POST a JSON body; the server answers 201 Created with the stored user.
+func httpPostJSON(ec *exampleContext, client *http.Client, baseURL string) {
+	body, _ := json.Marshal(user{Name: "Ferris"})
+	resp, err := client.Post(baseURL+"/users", "application/json", bytes.NewReader(body))
+	if err != nil {
+		ec.println("POST failed:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var u user
+	json.NewDecoder(resp.Body).Decode(&u)
+	ec.println("POST", resp.StatusCode, u.ID, u.Name)
+}
+
+// Client.Timeout covers the whole exchange: dialing, headers and reading the body.
+func httpTimeout(ec *exampleContext, baseURL string) {
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	resp, err := client.Get(baseURL + "/slow")
+	if err == nil {
+		resp.Body.Close()
+		ec.println("expected a timeout")
+		return
+	}
+	var netErr net.Error
+	ec.println("Timeout:", errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// headerTransport is a RoundTripper that adds a header to every request and counts them.
+type headerTransport struct {
+	base     http.RoundTripper
+	requests int32
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	// A RoundTripper must not modify the caller's request, so clone it first.
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Example", "custom transport")
+	return t.base.RoundTrip(req)
+}
+
+func httpCustomTransport(ec *exampleContext, baseURL string) {
+	base := &http.Transport{
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+	}
+	defer base.CloseIdleConnections()
+
+	transport := &headerTransport{base: base}
+	client := &http.Client{Transport: transport, Timeout: time.Second}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(baseURL + "/headers")
+		if err != nil {
+			ec.println("GET failed:", err)
+			return
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		ec.println("Server saw header:", strings.TrimSpace(string(b)))
+	}
+	ec.println("Requests through transport:", atomic.LoadInt32(&transport.requests))
+}
+
+// getWithRetry retries 5xx responses and transport errors with exponential backoff.
+func getWithRetry(ec *exampleContext, client *http.Client, url string, attempts int, backoff time.Duration) (*http.Response, error) {
+	var lastErr error
+	for i := 1; i <= attempts; i++ {
+		resp, err := client.Get(url)
+		switch {
+		case err != nil:
+			lastErr = err
+		case resp.StatusCode >= 500:
+			resp.Body.Close()
+			lastErr = fmt.Errorf("server returned %s", resp.Status)
+		default:
+			return resp, nil
+		}
+		ec.printf("attempt %d failed: %v\n", i, lastErr)
+		ec.clock.Sleep(backoff)
+		backoff *= 2
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", attempts, lastErr)
+}
+
+func httpRetry(ec *exampleContext, client *http.Client, baseURL string) {
+	resp, err := getWithRetry(ec, client, baseURL+"/flaky", 5, 10*time.Millisecond)
+	if err != nil {
+		ec.println(err)
+		return
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	ec.println("Retry succeeded:", strings.TrimSpace(string(b)))
+}
+
+// Read the body as a stream instead of loading it into memory with io.ReadAll.
+func httpStreaming(ec *exampleContext, client *http.Client, baseURL string) {
+	resp, err := client.Get(baseURL + "/stream")
+	if err != nil {
+		ec.println("GET failed:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		ec.println("Streamed:", scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		ec.println("stream failed:", err)
+	}
+}
+
+/* Output:
+% go run . run http-client
+2023/03/12 17:11:57 Welcome to Go Concepts examples
+2023/03/12 17:11:57 Running http-client
+HTTP Client Example
+GET 200 1 Gopher
+POST 201 2 Ferris
+Timeout: true
+Server saw header: custom transport
+Server saw header: custom transport
+Requests through transport: 2
+attempt 1 failed: server returned 503 Service Unavailable
+attempt 2 failed: server returned 503 Service Unavailable
+Retry succeeded: ok
+Streamed: event 1
+Streamed: event 2
+Streamed: event 3
+*/
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// An interface value is two machine words: what the dynamic type is, and where its value is.
+/* The runtime has two layouts, described with the Interfaces section of methods_interfaces.go:
+
+	type eface struct {      // interface{} and any
+		_type *_type         // the dynamic type
+		data  unsafe.Pointer // the value, or a pointer to a copy of it
+	}
+
+	type iface struct {      // interfaces with methods
+		tab  *itab           // the interface type, the dynamic type and its method table
+		data unsafe.Pointer
+	}
+
+	type itab struct {
+		inter *interfacetype
+		_type *_type
+		hash  uint32         // copy of _type.hash, for type switches
+		fun   [1]uintptr     // method addresses, in the interface's method order
+	}
+
+Values that are pointers already, such as *T, maps and channels, are stored in the data
+word directly; anything else is copied, usually to the heap, and data points to the copy.
+An itab is built the first time a dynamic type is converted to an interface type, then
+cached: every fmt.Stringer holding a celsius shares the same tab word. Calling a method
+through an interface loads fun[i] from the itab and calls it indirectly, which the
+compiler cannot inline; the benchmarks in iface_internals_test.go measure the difference.
+
+An interface is nil only when both words are nil. A nil *T stored in an error has a
+non-nil tab, so err != nil holds even though the pointer inside is nil.
+*/
+type eface struct {
+	typ  unsafe.Pointer
+	data unsafe.Pointer
+}
+
+type iface struct {
+	tab  *itab
+	data unsafe.Pointer
+}
+
+type itab struct {
+	inter unsafe.Pointer
+	typ   unsafe.Pointer
+	hash  uint32
+	_     [4]byte
+	fun   [1]uintptr
+}
+
+// efaceOf returns the two words of an empty interface value.
+func efaceOf(v interface{}) eface {
+	return *(*eface)(unsafe.Pointer(&v))
+}
+
+// stringerWords returns the two words of a fmt.Stringer value.
+func stringerWords(s fmt.Stringer) iface {
+	return *(*iface)(unsafe.Pointer(&s))
+}
+
+// errorWords returns the two words of an error value.
+func errorWords(err error) iface {
+	return *(*iface)(unsafe.Pointer(&err))
+}
+
+func (e eface) String() string { return fmt.Sprintf("type=%p data=%p", e.typ, e.data) }
+func (i iface) String() string { return fmt.Sprintf("tab=%p data=%p", i.tab, i.data) }
+
+type celsius float64
+
+func (c celsius) String() string { return fmt.Sprintf("%.1f°C", float64(c)) }
+
+type shouter string
+
+func (s shouter) String() string { return string(s) + "!" }
+
+// notFoundError is returned as a pointer, the usual way for error types with fields.
+type notFoundError struct{ name string }
+
+func (e *notFoundError) Error() string { return e.name + " not found" }
+
+// findUserBuggy declares its result as *notFoundError and returns it as an error. When
+// the user exists the pointer is nil, but the error wrapping it is not.
+func findUserBuggy(name string) error {
+	var err *notFoundError
+	if name != "gopher" {
+		err = &notFoundError{name}
+	}
+	return err
+}
+
+// findUser returns a literal nil on success, so the error is nil too.
+func findUser(name string) error {
+	if name != "gopher" {
+		return &notFoundError{name}
+	}
+	return nil
+}
+
+// ifaceInternalsExample prints the words of interface values and shows the typed-nil error pitfall.
+func ifaceInternalsExample(ec *exampleContext) {
+	ec.println("Empty interfaces: a type word and a data word")
+	n := 42
+	p := &n
+	var a interface{} = n
+	var b interface{} = 7
+	var c interface{} = "hello"
+	var d interface{} = p
+	for _, v := range []struct {
+		expr string
+		val  interface{}
+	}{{"any(42)", a}, {"any(7)", b}, {`any("hello")`, c}, {"any(&n)", d}} {
+		ec.println(fmt.Sprintf("  %-13s %v", v.expr, efaceOf(v.val)))
+	}
+	ec.println("  any(42) and any(7) share a type word:", efaceOf(a).typ == efaceOf(b).typ)
+	ec.println("  any(&n) stores the pointer itself:", efaceOf(d).data == unsafe.Pointer(p))
+	ec.println("  any(42) points to a copy of n:", efaceOf(a).data != unsafe.Pointer(p), *(*int)(efaceOf(a).data))
+
+	ec.println("Non-empty interfaces: an itab word and a data word")
+	var s1 fmt.Stringer = celsius(21.5)
+	var s2 fmt.Stringer = celsius(-3)
+	var s3 fmt.Stringer = shouter("hi")
+	w1, w2, w3 := stringerWords(s1), stringerWords(s2), stringerWords(s3)
+	ec.println(fmt.Sprintf("  %-24s %v", "Stringer(celsius(21.5))", w1))
+	ec.println(fmt.Sprintf("  %-24s %v", "Stringer(shouter(\"hi\"))", w3))
+	ec.println("  both celsius values share one itab:", w1.tab == w2.tab)
+	ec.println("  celsius and shouter have different itabs:", w1.tab != w3.tab)
+	ec.println("  itab._type is the type word any(celsius) has:", w1.tab.typ == efaceOf(celsius(0)).typ)
+	ec.println("  their itab.hash words, used by type switches, differ:", w1.tab.hash != w3.tab.hash)
+
+	ec.println("itab identity across assertions")
+	var boxed interface{} = celsius(100)
+	x := boxed.(fmt.Stringer)
+	y, _ := boxed.(fmt.Stringer)
+	ec.println("  boxed.(fmt.Stringer) twice gives the same, cached itab:", stringerWords(x).tab == stringerWords(y).tab)
+	ec.println("  and the same itab as the direct conversion:", stringerWords(x).tab == w1.tab)
+	ec.println("  the assertion copies no value: data words are equal:", stringerWords(x).data == efaceOf(boxed).data)
+
+	ec.println("The typed-nil error pitfall")
+	err := findUserBuggy("gopher")
+	ec.println(fmt.Sprintf("  findUserBuggy(\"gopher\"): %v", errorWords(err)))
+	if err != nil {
+		ec.println("  err != nil is true: the check fails although the *notFoundError inside is nil")
+	}
+	var nf *notFoundError
+	ec.println("  errors.As still finds the nil pointer:", errors.As(err, &nf), nf == nil)
+	err = findUser("gopher")
+	ec.println(fmt.Sprintf("  findUser(\"gopher\"):      %v", errorWords(err)))
+	ec.println("  err == nil:", err == nil)
+	ec.println(`Run "go test -bench Dispatch" to compare direct, interface and generic calls`)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestInterfaceWords(t *testing.T) {
+	v := celsius(10)
+	p := &v
+	if got := efaceOf(p).data; got != unsafe.Pointer(p) {
+		t.Errorf("any(p).data = %p, want the pointer %p", got, p)
+	}
+	if efaceOf(v).typ != efaceOf(celsius(20)).typ {
+		t.Error("two celsius values have different type words")
+	}
+	if efaceOf(v).typ == efaceOf(shouter("x")).typ {
+		t.Error("celsius and shouter have the same type word")
+	}
+	if w := stringerWords(v); w.tab == nil || w.tab.typ != efaceOf(v).typ {
+		t.Errorf("Stringer(celsius) itab type = %v, want the type word of any(celsius)", w.tab)
+	}
+	if w := stringerWords(nil); w.tab != nil || w.data != nil {
+		t.Errorf("nil Stringer = %v, want both words nil", w)
+	}
+}
+
+func TestTypedNilError(t *testing.T) {
+	err := findUserBuggy("gopher")
+	if err == nil {
+		t.Fatal("findUserBuggy returned a nil error; the pitfall no longer shows")
+	}
+	if w := errorWords(err); w.tab == nil || w.data != nil {
+		t.Errorf("typed nil error = %v, want a type and a nil data word", w)
+	}
+	if err := findUser("gopher"); err != nil {
+		t.Errorf("findUser(gopher) = %v, want nil", err)
+	}
+	if err := findUser("nobody"); err == nil || err.Error() != "nobody not found" {
+		t.Errorf("findUser(nobody) = %v, want nobody not found", err)
+	}
+}
+
+type dispatchCounter struct{ n int }
+
+func (c *dispatchCounter) Add(d int) { c.n += d }
+
+type adder interface{ Add(int) }
+
+type otherAdder struct{ n int }
+
+func (o *otherAdder) Add(d int) { o.n -= d }
+
+//go:noinline
+func addDirectNoInline(c *dispatchCounter, d int) { c.n += d }
+
+//go:noinline
+func addGeneric[T adder](a T, d int) { a.Add(d) }
+
+// The benchmarks read their receivers from package variables, so the compiler cannot
+// see the dynamic type and turn the interface calls back into direct ones.
+var (
+	dispatchAdder adder
+	dispatchBoxed interface{}
+)
+
+// BenchmarkDispatch compares calling the same method directly, through an interface,
+// after a type switch or assertion, and through a generic function.
+func BenchmarkDispatch(b *testing.B) {
+	c := &dispatchCounter{}
+	dispatchAdder, dispatchBoxed = c, c
+
+	b.Run("direct", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.Add(1)
+		}
+	})
+	b.Run("direct-noinline", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			addDirectNoInline(c, 1)
+		}
+	})
+	b.Run("interface", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dispatchAdder.Add(1)
+		}
+	})
+	b.Run("type-switch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			switch v := dispatchAdder.(type) {
+			case *otherAdder:
+				v.Add(1)
+			case *dispatchCounter:
+				v.Add(1)
+			}
+		}
+	})
+	b.Run("assert-concrete", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dispatchBoxed.(*dispatchCounter).Add(1)
+		}
+	})
+	b.Run("assert-interface", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dispatchBoxed.(adder).Add(1)
+		}
+	})
+	b.Run("generic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			addGeneric(c, 1)
+		}
+	})
+	if c.n == 0 {
+		b.Fatal("no calls were made")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// The layout of a struct in memory is fixed by the compiler from the order of its fields.
+/* Every field starts at an offset that is a multiple of its alignment: 8 for int64,
+float64 and pointers on 64-bit platforms, 4 for int32, 1 for bool and byte. When a
+field would start at a bad offset, the compiler leaves padding bytes before it, and
+the struct's size is rounded up to its own alignment, the largest of its fields', so
+that the elements of an array of it stay aligned too.
+
+	type padded struct {  // size 24
+		a bool            // offset 0, then 7 bytes of padding
+		b int64           // offset 8
+		c bool            // offset 16, then 7 bytes of padding to round up to 24
+	}
+
+go-concepts layout reports the offset, size and alignment of every field, and the
+padding between them, using go/types with the sizes of the gc compiler on this
+machine's architecture. Sorting the fields from the largest alignment to the smallest
+never leaves a gap between them, so it is suggested whenever it makes the struct smaller:
+
+	go-concepts layout paddedRecord
+	go-concepts layout -all
+	go-concepts layout layout_examples.go
+*/
+type structLayout struct {
+	name    string
+	size    int64
+	align   int64
+	fields  []fieldLayout
+	padding int64 // total padding bytes, between fields and at the end
+}
+
+type fieldLayout struct {
+	name    string
+	typ     string
+	offset  int64
+	size    int64
+	align   int64
+	padding int64 // bytes of padding after this field
+}
+
+// layoutOf computes the layout of s with the given sizes.
+func layoutOf(sizes types.Sizes, name string, s *types.Struct) structLayout {
+	vars := make([]*types.Var, s.NumFields())
+	for i := range vars {
+		vars[i] = s.Field(i)
+	}
+	l := structLayout{name: name, size: sizes.Sizeof(s), align: sizes.Alignof(s)}
+	offsets := sizes.Offsetsof(vars)
+	for i, v := range vars {
+		f := fieldLayout{
+			name:   v.Name(),
+			typ:    types.TypeString(v.Type(), func(*types.Package) string { return "" }),
+			offset: offsets[i],
+			size:   sizes.Sizeof(v.Type()),
+			align:  sizes.Alignof(v.Type()),
+		}
+		end := l.size
+		if i+1 < len(vars) {
+			end = offsets[i+1]
+		}
+		f.padding = end - f.offset - f.size
+		l.padding += f.padding
+		l.fields = append(l.fields, f)
+	}
+	return l
+}
+
+// optimalOrder returns the fields of s sorted by decreasing alignment, keeping the
+// declared order among equals, and the size of a struct in that order.
+func optimalOrder(sizes types.Sizes, s *types.Struct) ([]*types.Var, int64) {
+	vars := make([]*types.Var, s.NumFields())
+	for i := range vars {
+		vars[i] = s.Field(i)
+	}
+	sort.SliceStable(vars, func(i, j int) bool {
+		return sizes.Alignof(vars[i].Type()) > sizes.Alignof(vars[j].Type())
+	})
+	return vars, sizes.Sizeof(types.NewStruct(vars, nil))
+}
+
+// writeLayout prints l as a table, followed by the suggested order if it saves space.
+func writeLayout(w io.Writer, l structLayout, better []*types.Var, betterSize int64) {
+	fmt.Fprintf(w, "%s  size %d  align %d  padding %d\n", l.name, l.size, l.align, l.padding)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "offset\tsize\talign\t  field")
+	for _, f := range l.fields {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t  %s %s\n", f.offset, f.size, f.align, f.name, f.typ)
+		if f.padding > 0 {
+			fmt.Fprintf(tw, "%d\t%d\t\t  (padding)\n", f.offset+f.size, f.padding)
+		}
+	}
+	tw.Flush()
+	if betterSize < l.size {
+		names := make([]string, len(better))
+		for i, v := range better {
+			names[i] = v.Name()
+		}
+		fmt.Fprintf(w, "  reorder as %s: size %d, saves %d bytes\n", strings.Join(names, ", "), betterSize, l.size-betterSize)
+	}
+}
+
+// layoutCommand implements "go-concepts layout".
+func layoutCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("layout", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", ".", "directory of the package to load")
+	all := fs.Bool("all", false, "report every struct type in the package")
+	names, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	path := *dir
+	if len(names) > 0 && strings.HasSuffix(names[0], ".go") {
+		path, names = names[0], names[1:]
+	}
+	if len(names) == 0 && !*all && path == *dir {
+		fmt.Fprintln(stderr, "usage: go-concepts layout [-dir dir] [file.go] <type>... | -all")
+		return 2
+	}
+
+	lp, err := loadPackage(path)
+	if err != nil {
+		fmt.Fprintln(stderr, "layout:", err)
+		return 1
+	}
+	var targets []*types.TypeName
+	if len(names) == 0 {
+		targets = lp.namedTypes()
+	}
+	for _, name := range names {
+		tn, err := lp.lookupType(name)
+		if err != nil {
+			fmt.Fprintln(stderr, "layout:", err)
+			return 1
+		}
+		targets = append(targets, tn)
+	}
+
+	reported, code := 0, 0
+	for _, tn := range targets {
+		s, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok || isGeneric(tn) {
+			if len(names) == 0 {
+				continue
+			}
+			if !ok {
+				fmt.Fprintf(stderr, "layout: %s is not a struct type\n", tn.Name())
+			} else {
+				fmt.Fprintf(stderr, "layout: %s is generic; its layout depends on the type arguments\n", tn.Name())
+			}
+			return 1
+		}
+		if bad := unresolvedFields(s); len(bad) > 0 {
+			// A field whose type did not type-check has no real size, so no offset after
+			// it can be trusted either.
+			for _, f := range bad {
+				msg := lp.errorAt(f.Pos())
+				if msg == "" {
+					msg = fmt.Sprintf("%s contains a type that did not type-check", types.TypeString(f.Type(), types.RelativeTo(lp.pkg)))
+				}
+				fmt.Fprintf(stderr, "layout: cannot lay out %s: field %s: %s\n", tn.Name(), f.Name(), msg)
+			}
+			code = 1
+			continue
+		}
+		if reported > 0 {
+			fmt.Fprintln(stdout)
+		}
+		better, size := optimalOrder(lp.sizes, s)
+		writeLayout(stdout, layoutOf(lp.sizes, tn.Name(), s), better, size)
+		reported++
+	}
+	return code
+}
+
+// unresolvedFields returns the fields of s whose size depends on a type that did not
+// type-check.
+func unresolvedFields(s *types.Struct) []*types.Var {
+	var fields []*types.Var
+	for i := 0; i < s.NumFields(); i++ {
+		if hasInvalidSize(s.Field(i).Type(), map[types.Type]bool{}) {
+			fields = append(fields, s.Field(i))
+		}
+	}
+	return fields
+}
+
+// hasInvalidSize reports whether the size of t depends on an invalid type: t itself,
+// or the element of an array or the field of a struct that is stored inline. Pointers,
+// slices, maps and the like have a fixed size whatever they refer to.
+func hasInvalidSize(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return u.Kind() == types.Invalid
+	case *types.Array:
+		return hasInvalidSize(u.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if hasInvalidSize(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// paddedRecord declares its fields in an order that wastes space: each bool is
+// followed by padding so the next 8-byte field starts at a multiple of 8.
+type paddedRecord struct {
+	active  bool
+	id      int64
+	visible bool
+	score   float64
+	flags   uint8
+	count   int32
+	name    string
+}
+
+// packedRecord has the same fields as paddedRecord, sorted from the largest
+// alignment to the smallest, as "go-concepts layout paddedRecord" suggests.
+type packedRecord struct {
+	id      int64
+	score   float64
+	name    string
+	count   int32
+	active  bool
+	visible bool
+	flags   uint8
+}
+
+// point needs no reordering: its fields are all the same size.
+type point struct {
+	x, y, z float64
+}
+
+// structLayoutExample measures paddedRecord and packedRecord with unsafe, the way the
+// compiler laid them out, and shows what the difference costs over many records.
+func structLayoutExample(ec *exampleContext) {
+	var p paddedRecord
+	var q packedRecord
+	ec.println("Struct layout on a 64-bit platform")
+	ec.println(fmt.Sprintf("paddedRecord: size %d, align %d", unsafe.Sizeof(p), unsafe.Alignof(p)))
+	ec.println(fmt.Sprintf("  active@%d id@%d visible@%d score@%d flags@%d count@%d name@%d",
+		unsafe.Offsetof(p.active), unsafe.Offsetof(p.id), unsafe.Offsetof(p.visible), unsafe.Offsetof(p.score),
+		unsafe.Offsetof(p.flags), unsafe.Offsetof(p.count), unsafe.Offsetof(p.name)))
+	ec.println(fmt.Sprintf("packedRecord: size %d, align %d", unsafe.Sizeof(q), unsafe.Alignof(q)))
+	ec.println(fmt.Sprintf("  id@%d score@%d name@%d count@%d active@%d visible@%d flags@%d",
+		unsafe.Offsetof(q.id), unsafe.Offsetof(q.score), unsafe.Offsetof(q.name), unsafe.Offsetof(q.count),
+		unsafe.Offsetof(q.active), unsafe.Offsetof(q.visible), unsafe.Offsetof(q.flags)))
+	ec.println(fmt.Sprintf("point: size %d, no padding", unsafe.Sizeof(point{})))
+
+	const n = 1_000_000
+	saved := (unsafe.Sizeof(p) - unsafe.Sizeof(q)) * n
+	ec.println(fmt.Sprintf("A []paddedRecord of %d elements uses %d MB, a []packedRecord %d MB: %d MB saved",
+		n, unsafe.Sizeof(p)*n>>20, unsafe.Sizeof(q)*n>>20, saved>>20))
+	ec.println(`Run "go-concepts layout paddedRecord packedRecord" for the field-by-field report`)
+}
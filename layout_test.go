@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testStruct(fields ...types.Type) *types.Struct {
+	vars := make([]*types.Var, len(fields))
+	for i, typ := range fields {
+		vars[i] = types.NewField(token.NoPos, nil, string(rune('a'+i)), typ, false)
+	}
+	return types.NewStruct(vars, nil)
+}
+
+func TestLayoutOf(t *testing.T) {
+	sizes := types.SizesFor("gc", "amd64")
+	s := testStruct(types.Typ[types.Bool], types.Typ[types.Int64], types.Typ[types.Bool])
+	l := layoutOf(sizes, "padded", s)
+	if l.size != 24 || l.align != 8 || l.padding != 14 {
+		t.Errorf("size %d align %d padding %d, want 24, 8, 14", l.size, l.align, l.padding)
+	}
+	wantOffsets := []int64{0, 8, 16}
+	wantPadding := []int64{7, 0, 7}
+	for i, f := range l.fields {
+		if f.offset != wantOffsets[i] || f.padding != wantPadding[i] {
+			t.Errorf("field %s: offset %d padding %d, want %d and %d", f.name, f.offset, f.padding, wantOffsets[i], wantPadding[i])
+		}
+	}
+
+	order, size := optimalOrder(sizes, s)
+	if size != 16 || order[0].Name() != "b" || order[1].Name() != "a" || order[2].Name() != "c" {
+		t.Errorf("optimal order %v size %d, want b, a, c and 16", order, size)
+	}
+
+	var buf bytes.Buffer
+	writeLayout(&buf, l, order, size)
+	for _, want := range []string{"padded  size 24  align 8  padding 14", "(padding)", "reorder as b, a, c: size 16, saves 8 bytes"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+// TestLayoutCommand type-checks layout_examples.go from source and checks that
+// go/types agrees with what the compiler did, as measured by the example.
+func TestLayoutCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type-checks the standard library from source")
+	}
+	var stdout, stderr bytes.Buffer
+	if code := layoutCommand([]string{"layout_examples.go", "paddedRecord", "packedRecord"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"paddedRecord  size 56  align 8  padding 17",
+		"reorder as id, score, name, count, active, visible, flags: size 40, saves 16 bytes",
+		"packedRecord  size 40  align 8  padding 1",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, stdout.String())
+		}
+	}
+	if strings.Count(stdout.String(), "reorder as") != 1 {
+		t.Errorf("only paddedRecord should get a suggestion:\n%s", stdout.String())
+	}
+}
+
+// TestLayoutCommandGeneric checks that generic structs, which have no layout until
+// they are instantiated, are skipped by -all and rejected by name.
+func TestLayoutCommandGeneric(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pair.go")
+	src := "package pair\n\ntype pair[K comparable, V any] struct {\n\tkey K\n\tval V\n}\n\ntype entry struct {\n\tok bool\n\tn  int64\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := layoutCommand([]string{"-all", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("-all: exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "entry  size 16") || strings.Contains(stdout.String(), "pair") {
+		t.Errorf("-all should report entry only:\n%s", stdout.String())
+	}
+	stderr.Reset()
+	if code := layoutCommand([]string{path, "pair"}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "pair is generic") {
+		t.Errorf("pair: exit code %d, stderr %q; want 1 and an error", code, stderr.String())
+	}
+}
+
+// TestLayoutCommandUnresolvedField checks that a struct with a field whose type did not
+// type-check is reported as an error rather than laid out with a made-up size.
+func TestLayoutCommandUnresolvedField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.go")
+	src := "package bad\n\ntype rec struct {\n\tok   bool\n\twhen Missing\n\tn    int64\n}\n\ntype nested struct {\n\tr [2]rec\n}\n\ntype good struct {\n\tn int64\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := layoutCommand([]string{"-all", path}, &stdout, &stderr); code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+	for _, want := range []string{
+		"layout: cannot lay out rec: field when: undefined: Missing",
+		"layout: cannot lay out nested: field r: [2]rec contains a type that did not type-check",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr is missing %q:\n%s", want, stderr.String())
+		}
+	}
+	if !strings.Contains(stdout.String(), "good  size 8") || strings.Contains(stdout.String(), "rec") {
+		t.Errorf("only good should be laid out:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A goroutine leaks when nothing will ever let it finish.
+/* The classic cause is a send or receive on a channel that no one will use again:
+
+	go func() { chan2 <- "chan2" }() // blocks forever if the select loop already returned
+
+A leaked goroutine is never collected, and neither is anything its stack refers to.
+The leak check takes a snapshot of the live goroutines before an example runs and
+compares it with the goroutines alive after it returns. Goroutines that are shutting
+down may need a moment to finish, so the check retries until settleFor has passed
+before it reports the new goroutines that are still there, with their stacks.
+*/
+type goroutineSnapshot map[int]bool
+
+// snapshotGoroutines records the IDs of the goroutines alive now.
+func snapshotGoroutines() goroutineSnapshot {
+	s := goroutineSnapshot{}
+	for _, g := range parseGoroutines(dumpGoroutines()) {
+		s[g.id] = true
+	}
+	return s
+}
+
+// leaked returns the goroutines that did not exist at the snapshot and are still
+// alive after waiting up to settleFor for them to exit. The caller is never reported.
+func (s goroutineSnapshot) leaked(settleFor time.Duration) []goroutineInfo {
+	self := currentGoroutineID()
+	deadline := time.Now().Add(settleFor)
+	for {
+		var leaks []goroutineInfo
+		for _, g := range parseGoroutines(dumpGoroutines()) {
+			if !s[g.id] && g.id != self {
+				leaks = append(leaks, g)
+			}
+		}
+		if len(leaks) == 0 || time.Now().After(deadline) {
+			return leaks
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// formatLeaks describes leaked goroutines the way a goroutine dump does.
+func formatLeaks(leaks []goroutineInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d goroutine(s) leaked:\n", len(leaks))
+	for _, g := range leaks {
+		fmt.Fprintf(&b, "\ngoroutine %d [%s]:\n%s\n", g.id, g.state, strings.TrimRight(g.stack, "\n"))
+	}
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakySelect returns as soon as chan1 delivers, forgetting the goroutine that sends on chan2.
+// chan2 is returned only so the test can unblock that goroutine once it has been reported.
+func leakySelect(release chan struct{}) (string, chan string) {
+	chan1 := make(chan string)
+	chan2 := make(chan string)
+	go func() { chan1 <- "chan1" }()
+	go func() {
+		<-release
+		chan2 <- "chan2" // no one receives this: blocks forever
+	}()
+	return <-chan1, chan2
+}
+
+func TestLeakCheckReportsBlockedGoroutine(t *testing.T) {
+	before := snapshotGoroutines()
+	release := make(chan struct{})
+	_, chan2 := leakySelect(release)
+	close(release)
+	defer func() { <-chan2 }()
+
+	leaks := before.leaked(50 * time.Millisecond)
+	if len(leaks) != 1 {
+		t.Fatalf("got %d leaked goroutines, want 1:\n%s", len(leaks), formatLeaks(leaks))
+	}
+	report := formatLeaks(leaks)
+	for _, want := range []string{"1 goroutine(s) leaked", "[chan send]", ".leakySelect.func2()"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report is missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestLeakCheckWaitsForExitingGoroutines(t *testing.T) {
+	before := snapshotGoroutines()
+	go time.Sleep(20 * time.Millisecond)
+	if leaks := before.leaked(time.Second); len(leaks) != 0 {
+		t.Errorf("a goroutine that exits on its own was reported:\n%s", formatLeaks(leaks))
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "log"
+import (
+	"log"
+	"os"
+)
 
 /*
 Advantages of Using Go
@@ -55,11 +58,7 @@ This is why Golang is recognized by many big names across various industries.
 func main() {
 	log.Println("Welcome to Go Concepts examples")
 
-	//goRoutineExample()
-
-	channelExample()
-
-	//httpClient()
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
 }
 
 /*
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+)
+
+// simMap is a user-space copy of the hash map described in the Maps sections of types_moretypes.go.
+/* It follows runtime/map.go as it was up to Go 1.23; Go 1.24 replaced it with Swiss tables,
+but the ideas below carry over.
+
+type simMap[K comparable, V any] struct {
+	count      int          // live entries, len(m)
+	B          uint8        // log2 of the number of buckets
+	noverflow  uint16       // approximate number of overflow buckets
+	hash0      uint64       // hash seed
+	buckets    []*bmap      // 2^B buckets
+	oldbuckets []*bmap      // the previous buckets, while growing
+	nevacuate  uint         // old buckets below this have been evacuated
+}
+
+A bucket holds 8 entries. Next to them it stores the top byte of each key's hash, its
+tophash, so a lookup compares one byte per slot and only compares keys whose tophash
+matches. The low B bits of the hash choose the bucket. A full bucket links an overflow
+bucket. Tophash values below 5 are not hashes but slot states: emptyRest (this slot and
+every one after it is empty, so a lookup can stop), emptyOne, and the evacuatedX,
+evacuatedY and evacuatedEmpty marks left in old buckets.
+
+When an insert would take the average above 6.5 entries per bucket, the map doubles.
+When it has about as many overflow buckets as buckets, usually after many deletes, it
+grows to the same size to pack the entries again. Growing only allocates the new buckets;
+the entries are moved, evacuated, a bucket or two at a time by the writes that follow,
+so no single insert pays for copying the whole map. An old bucket splits in two: entries
+whose hash has the new bit set go to bucket i+2^(B-1) (Y), the others stay at i (X).
+Until its old bucket is evacuated, a key is looked up there.
+
+Every change of state is reported to the onStep hook with a dump of the buckets.
+*/
+type simMap[K comparable, V any] struct {
+	count        int
+	B            uint8
+	noverflow    uint16
+	hash0        uint64
+	buckets      []*bmap[K, V]
+	oldbuckets   []*bmap[K, V]
+	nevacuate    uint
+	sameSizeGrow bool
+
+	hasher func(key K, seed uint64) uint64
+	onStep func(mapStep)
+}
+
+type bmap[K comparable, V any] struct {
+	tophash  [bucketCnt]uint8
+	keys     [bucketCnt]K
+	elems    [bucketCnt]V
+	overflow *bmap[K, V]
+}
+
+// mapStep is one operation on a simMap and the state it left behind.
+type mapStep struct {
+	op    string // "set", "delete", "grow" or "evacuate"
+	note  string
+	state string
+}
+
+const (
+	bucketCnt = 8
+
+	// Growth starts when count > loadFactorNum/loadFactorDen * 2^B.
+	loadFactorNum = 13
+	loadFactorDen = 2
+
+	emptyRest      = 0 // this slot is empty, and so is every later slot and overflow bucket
+	emptyOne       = 1 // this slot is empty
+	evacuatedX     = 2 // the entry moved to the first half of the new buckets
+	evacuatedY     = 3 // the entry moved to the second half
+	evacuatedEmpty = 4 // the slot was empty when its bucket was evacuated
+	minTopHash     = 5 // smallest tophash of a real entry
+)
+
+// newSimMap returns a map with room for hint entries, like make(map[K]V, hint).
+func newSimMap[K comparable, V any](hint int, seed uint64) *simMap[K, V] {
+	m := &simMap[K, V]{hash0: seed, hasher: fnvHash[K]}
+	for overLoadFactor(hint, m.B) {
+		m.B++
+	}
+	m.buckets = makeBuckets[K, V](m.B)
+	return m
+}
+
+// fnvHash hashes the printed form of key. The runtime uses AES or wyhash on the key's memory;
+// any hash works as long as equal keys hash equally and every bit of the key reaches the top
+// byte, hence the final mixing step of MurmurHash3.
+func fnvHash[K comparable](key K, seed uint64) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%#v", seed, key)
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func makeBuckets[K comparable, V any](b uint8) []*bmap[K, V] {
+	buckets := make([]*bmap[K, V], 1<<b)
+	for i := range buckets {
+		buckets[i] = &bmap[K, V]{}
+	}
+	return buckets
+}
+
+func overLoadFactor(count int, b uint8) bool {
+	return count > bucketCnt && count > loadFactorNum*((1<<b)/loadFactorDen)
+}
+
+// tooManyOverflowBuckets reports whether there are about as many overflow buckets as buckets.
+func tooManyOverflowBuckets(noverflow uint16, b uint8) bool {
+	if b > 15 {
+		b = 15
+	}
+	return noverflow >= uint16(1)<<(b&15)
+}
+
+func tophash(hash uint64) uint8 {
+	top := uint8(hash >> 56)
+	if top < minTopHash {
+		top += minTopHash
+	}
+	return top
+}
+
+func isEmpty(top uint8) bool { return top <= emptyOne }
+
+func evacuated[K comparable, V any](b *bmap[K, V]) bool {
+	h := b.tophash[0]
+	return h > emptyOne && h < minTopHash
+}
+
+func (m *simMap[K, V]) hash(key K) uint64  { return m.hasher(key, m.hash0) }
+func (m *simMap[K, V]) bucketMask() uint64 { return 1<<m.B - 1 }
+func (m *simMap[K, V]) growing() bool      { return m.oldbuckets != nil }
+func (m *simMap[K, V]) len() int           { return m.count }
+
+func (m *simMap[K, V]) step(op, format string, args ...interface{}) {
+	if m.onStep != nil {
+		m.onStep(mapStep{op: op, note: fmt.Sprintf(format, args...), state: m.String()})
+	}
+}
+
+// get returns the element for key and whether it is present: v, ok := m[key].
+func (m *simMap[K, V]) get(key K) (V, bool) {
+	var zero V
+	if m.count == 0 {
+		return zero, false
+	}
+	hash := m.hash(key)
+	b := m.buckets[hash&m.bucketMask()]
+	if m.growing() {
+		mask := m.bucketMask()
+		if !m.sameSizeGrow {
+			mask >>= 1
+		}
+		if old := m.oldbuckets[hash&mask]; !evacuated(old) {
+			b = old
+		}
+	}
+	top := tophash(hash)
+	for ; b != nil; b = b.overflow {
+		for i := 0; i < bucketCnt; i++ {
+			if b.tophash[i] != top {
+				if b.tophash[i] == emptyRest {
+					return zero, false
+				}
+				continue
+			}
+			if b.keys[i] == key {
+				return b.elems[i], true
+			}
+		}
+	}
+	return zero, false
+}
+
+// set inserts or updates key: m[key] = elem.
+func (m *simMap[K, V]) set(key K, elem V) {
+again:
+	hash := m.hash(key)
+	bucket := hash & m.bucketMask()
+	if m.growing() {
+		m.growWork(bucket)
+	}
+	top := tophash(hash)
+
+	var insertb *bmap[K, V]
+	var inserti int
+	b := m.buckets[bucket]
+bucketloop:
+	for {
+		for i := 0; i < bucketCnt; i++ {
+			if b.tophash[i] != top {
+				if isEmpty(b.tophash[i]) && insertb == nil {
+					insertb, inserti = b, i
+				}
+				if b.tophash[i] == emptyRest {
+					break bucketloop
+				}
+				continue
+			}
+			if b.keys[i] != key {
+				continue
+			}
+			b.elems[i] = elem
+			m.step("set", "%v updated in bucket %d", key, bucket)
+			return
+		}
+		if b.overflow == nil {
+			break
+		}
+		b = b.overflow
+	}
+
+	// The key is new. Growing now moves it to the new buckets, so look again.
+	if !m.growing() && (overLoadFactor(m.count+1, m.B) || tooManyOverflowBuckets(m.noverflow, m.B)) {
+		m.hashGrow()
+		goto again
+	}
+	if insertb == nil {
+		insertb, inserti = m.newoverflow(b), 0
+	}
+	insertb.tophash[inserti] = top
+	insertb.keys[inserti] = key
+	insertb.elems[inserti] = elem
+	m.count++
+	m.step("set", "%v inserted in bucket %d with tophash %02x", key, bucket, top)
+}
+
+// remove deletes key: delete(m, key).
+func (m *simMap[K, V]) remove(key K) {
+	if m.count == 0 {
+		return
+	}
+	hash := m.hash(key)
+	bucket := hash & m.bucketMask()
+	if m.growing() {
+		m.growWork(bucket)
+	}
+	top := tophash(hash)
+	first := m.buckets[bucket]
+	for b := first; b != nil; b = b.overflow {
+		for i := 0; i < bucketCnt; i++ {
+			if b.tophash[i] != top {
+				if b.tophash[i] == emptyRest {
+					return
+				}
+				continue
+			}
+			if b.keys[i] != key {
+				continue
+			}
+			var zeroK K
+			var zeroV V
+			b.keys[i], b.elems[i] = zeroK, zeroV
+			b.tophash[i] = emptyOne
+			if lastInUse(b, i) {
+				markEmptyRest(first, b, i)
+			}
+			m.count--
+			if m.count == 0 {
+				// Reseed, so an attacker cannot keep colliding keys across resets.
+				m.hash0 = m.hash0*6364136223846793005 + 1442695040888963407
+			}
+			m.step("delete", "%v deleted from bucket %d", key, bucket)
+			return
+		}
+	}
+}
+
+// lastInUse reports whether every slot after slot i of b's chain is emptyRest.
+func lastInUse[K comparable, V any](b *bmap[K, V], i int) bool {
+	if i == bucketCnt-1 {
+		return b.overflow == nil || b.overflow.tophash[0] == emptyRest
+	}
+	return b.tophash[i+1] == emptyRest
+}
+
+// markEmptyRest turns the run of emptyOne slots that ends at slot i of b into emptyRest,
+// walking backwards through the chain that starts at first.
+func markEmptyRest[K comparable, V any](first, b *bmap[K, V], i int) {
+	for {
+		b.tophash[i] = emptyRest
+		if i > 0 {
+			i--
+		} else {
+			if b == first {
+				return
+			}
+			prev := first
+			for prev.overflow != b {
+				prev = prev.overflow
+			}
+			b, i = prev, bucketCnt-1
+		}
+		if b.tophash[i] != emptyOne {
+			return
+		}
+	}
+}
+
+func (m *simMap[K, V]) newoverflow(b *bmap[K, V]) *bmap[K, V] {
+	ovf := &bmap[K, V]{}
+	m.noverflow++
+	b.overflow = ovf
+	return ovf
+}
+
+// hashGrow allocates the new buckets. The entries stay where they are until evacuated.
+func (m *simMap[K, V]) hashGrow() {
+	m.sameSizeGrow = !overLoadFactor(m.count+1, m.B)
+	m.oldbuckets = m.buckets
+	if !m.sameSizeGrow {
+		m.B++
+	}
+	m.buckets = makeBuckets[K, V](m.B)
+	m.nevacuate = 0
+	m.noverflow = 0
+	if m.sameSizeGrow {
+		m.step("grow", "too many overflow buckets: same-size grow to %d buckets", len(m.buckets))
+	} else {
+		m.step("grow", "load factor over 6.5: %d buckets -> %d", len(m.oldbuckets), len(m.buckets))
+	}
+}
+
+// growWork evacuates the old bucket that bucket is about to use, and one more to make progress.
+func (m *simMap[K, V]) growWork(bucket uint64) {
+	m.evacuate(uint(bucket) & uint(len(m.oldbuckets)-1))
+	if m.growing() {
+		m.evacuate(m.nevacuate)
+	}
+}
+
+// evacuate moves the entries of oldbuckets[oldbucket] to the new buckets.
+func (m *simMap[K, V]) evacuate(oldbucket uint) {
+	b := m.oldbuckets[oldbucket]
+	newbit := uint(len(m.oldbuckets))
+	if !evacuated(b) {
+		type evacDst struct {
+			b *bmap[K, V]
+			i int
+		}
+		x := evacDst{b: m.buckets[oldbucket]}
+		var y evacDst
+		if !m.sameSizeGrow {
+			y.b = m.buckets[oldbucket+newbit]
+		}
+		for ; b != nil; b = b.overflow {
+			for i := 0; i < bucketCnt; i++ {
+				top := b.tophash[i]
+				if isEmpty(top) {
+					b.tophash[i] = evacuatedEmpty
+					continue
+				}
+				dst := &x
+				b.tophash[i] = evacuatedX
+				if !m.sameSizeGrow && uint(m.hash(b.keys[i]))&newbit != 0 {
+					dst = &y
+					b.tophash[i] = evacuatedY
+				}
+				if dst.i == bucketCnt {
+					dst.b, dst.i = m.newoverflow(dst.b), 0
+				}
+				dst.b.tophash[dst.i] = top
+				dst.b.keys[dst.i] = b.keys[i]
+				dst.b.elems[dst.i] = b.elems[i]
+				dst.i++
+				var zeroK K
+				var zeroV V
+				b.keys[i], b.elems[i] = zeroK, zeroV
+			}
+		}
+		m.step("evacuate", "old bucket %d", oldbucket)
+	}
+	if oldbucket == m.nevacuate {
+		m.nevacuate++
+		for m.nevacuate < newbit && evacuated(m.oldbuckets[m.nevacuate]) {
+			m.nevacuate++
+		}
+		if m.nevacuate == newbit {
+			m.oldbuckets = nil
+			m.step("grow", "every old bucket evacuated, growth done")
+		}
+	}
+}
+
+// iterate calls f for each entry the way a range loop visits them. The runtime picks r
+// at random for every range statement: the bucket to start at is r's low B bits and the
+// slot to start at in each bucket is the 3 bits above them.
+func (m *simMap[K, V]) iterate(r uint64, f func(K, V)) {
+	nbuckets := uint64(len(m.buckets))
+	start := r & m.bucketMask()
+	offset := int(r >> m.B & (bucketCnt - 1))
+	for n := uint64(0); n < nbuckets; n++ {
+		bucket := (start + n) % nbuckets
+		b := m.buckets[bucket]
+		check := false
+		if m.growing() {
+			// Entries still in an old bucket are visited from there; when the old
+			// bucket splits, only those headed for this new bucket count here.
+			old := m.oldbuckets[bucket&uint64(len(m.oldbuckets)-1)]
+			if !evacuated(old) {
+				b, check = old, !m.sameSizeGrow
+			}
+		}
+		for ; b != nil; b = b.overflow {
+			for i := 0; i < bucketCnt; i++ {
+				slot := (i + offset) & (bucketCnt - 1)
+				if isEmpty(b.tophash[slot]) || b.tophash[slot] == evacuatedEmpty {
+					continue
+				}
+				if check && m.hash(b.keys[slot])&m.bucketMask() != bucket {
+					continue
+				}
+				f(b.keys[slot], b.elems[slot])
+			}
+		}
+	}
+}
+
+// keys returns the keys in the order a range loop starting from r visits them.
+func (m *simMap[K, V]) keys(r uint64) []K {
+	var out []K
+	m.iterate(r, func(k K, _ V) { out = append(out, k) })
+	return out
+}
+
+// String dumps the header and every bucket chain. Slots show tophash:key=elem, "_" for
+// emptyRest, "." for emptyOne and X, Y or "-" for evacuated slots of old buckets.
+func (m *simMap[K, V]) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "count=%d B=%d noverflow=%d", m.count, m.B, m.noverflow)
+	if m.growing() {
+		fmt.Fprintf(&sb, " growing oldbuckets=%d nevacuate=%d", len(m.oldbuckets), m.nevacuate)
+	}
+	writeBuckets(&sb, "bucket", m.buckets)
+	if m.growing() {
+		writeBuckets(&sb, "old", m.oldbuckets)
+	}
+	return sb.String()
+}
+
+func writeBuckets[K comparable, V any](sb *strings.Builder, label string, buckets []*bmap[K, V]) {
+	for i, b := range buckets {
+		fmt.Fprintf(sb, "\n  %s %d:", label, i)
+		for ; b != nil; b = b.overflow {
+			if b != buckets[i] {
+				sb.WriteString(" ->")
+			}
+			slots := make([]string, bucketCnt)
+			for j, top := range b.tophash {
+				switch top {
+				case emptyRest:
+					slots[j] = "_"
+				case emptyOne:
+					slots[j] = "."
+				case evacuatedX:
+					slots[j] = "X"
+				case evacuatedY:
+					slots[j] = "Y"
+				case evacuatedEmpty:
+					slots[j] = "-"
+				default:
+					slots[j] = fmt.Sprintf("%02x:%v=%v", top, b.keys[j], b.elems[j])
+				}
+			}
+			fmt.Fprintf(sb, " [%s]", strings.Join(slots, " "))
+		}
+	}
+}
+
+// mapInternalsExample runs the Maps sections of types_moretypes.go on a simulated map,
+// dumping its buckets, then shows why range over a map has no fixed order.
+func mapInternalsExample(ec *exampleContext) {
+	ec.println("Map simulator: m := make(map[string]int)")
+	m := newSimMap[string, int](0, 1)
+	m.onStep = func(s mapStep) {
+		ec.printf("%-8s %s\n  %s\n", s.op, s.note, s.state)
+	}
+	m.set("Bell Labs", 1)
+	m.set("Google", 2)
+	m.set("Bell Labs", 3)
+	v, ok := m.get("Google")
+	ec.println(`v, ok := m["Google"] ->`, v, ok)
+	v, ok = m.get("Apple")
+	ec.println(`v, ok := m["Apple"] ->`, v, ok)
+	m.set("Apple", 4)
+	m.remove("Google")
+	m.remove("Apple")
+
+	ec.println("Inserting until the load factor passes 6.5 entries per bucket")
+	m = newSimMap[string, int](0, 1)
+	m.onStep = func(s mapStep) {
+		// Dump every step of the growth from 4 to 8 buckets; before it, only the growths.
+		if len(m.buckets) == 8 {
+			ec.printf("%-8s %s\n  %s\n", s.op, s.note, s.state)
+		} else if s.op == "grow" {
+			ec.printf("%-8s %s\n", s.op, s.note)
+		}
+	}
+	for i := 0; len(m.buckets) < 8 || m.growing(); i++ {
+		m.set(fmt.Sprintf("k%d", i), i)
+	}
+	m.onStep = nil
+	ec.println("count", m.len(), "buckets", len(m.buckets), "overflow buckets", m.noverflow)
+
+	ec.println("Iteration order")
+	small := newSimMap[string, int](0, 7)
+	for i, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		small.set(k, i)
+	}
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 3; i++ {
+		r := rng.Uint64()
+		ec.println(fmt.Sprintf("range starting at bucket %d, slot %d: %v", r&small.bucketMask(), r>>small.B&7, small.keys(r)))
+	}
+	ec.println("Without the random start, a range would visit keys in bucket order, an order that")
+	ec.println("changes as soon as the map grows; programs relying on it would break at random.")
+
+	builtin := map[string]int{}
+	for i, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		builtin[k] = i
+	}
+	orders := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		var order []string
+		for k := range builtin {
+			order = append(order, k)
+		}
+		orders[strings.Join(order, "")] = true
+	}
+	ec.println("built-in map ranged 100 times, order varied:", len(orders) > 1)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkSameContents compares every key of the simulated map, visited by iterate, with want.
+func checkSameContents(t *testing.T, step int, m *simMap[int, int], want map[int]int, r uint64) {
+	t.Helper()
+	seen := map[int]bool{}
+	m.iterate(r, func(k, v int) {
+		if seen[k] {
+			t.Fatalf("step %d: iterate visited %d twice", step, k)
+		}
+		seen[k] = true
+		if w, ok := want[k]; !ok || w != v {
+			t.Fatalf("step %d: iterate saw %d=%d, built-in map has %d (present %t)", step, k, v, w, ok)
+		}
+	})
+	if len(seen) != len(want) {
+		t.Fatalf("step %d: iterate visited %d keys, built-in map has %d", step, len(seen), len(want))
+	}
+}
+
+// TestSimMapMatchesBuiltin applies the same random inserts, updates, deletes and lookups
+// to a simMap and a built-in map and expects the same results after every step, also while
+// the simulated map is growing. The weak hasher puts keys in few buckets, so long overflow
+// chains get exercised too.
+func TestSimMapMatchesBuiltin(t *testing.T) {
+	hashers := map[string]func(int, uint64) uint64{
+		"fnv":  fnvHash[int],
+		"weak": func(k int, seed uint64) uint64 { return uint64(k%5)<<56 | uint64(k%3) },
+	}
+	for name, hasher := range hashers {
+		for _, keySpace := range []int{10, 100, 2000} {
+			rng := rand.New(rand.NewSource(int64(keySpace)))
+			sim := newSimMap[int, int](0, 42)
+			sim.hasher = hasher
+			want := map[int]int{}
+			for step := 0; step < 3000; step++ {
+				k := rng.Intn(keySpace)
+				switch op := rng.Intn(10); {
+				case op < 5:
+					sim.set(k, step)
+					want[k] = step
+				case op < 8:
+					sim.remove(k)
+					delete(want, k)
+				default:
+					got, ok := sim.get(k)
+					w, wok := want[k]
+					if got != w || ok != wok {
+						t.Fatalf("%s/%d step %d: get(%d) = %d, %t; built-in %d, %t", name, keySpace, step, k, got, ok, w, wok)
+					}
+				}
+				if sim.len() != len(want) {
+					t.Fatalf("%s/%d step %d: len = %d, built-in %d", name, keySpace, step, sim.len(), len(want))
+				}
+				if step%100 == 0 {
+					checkSameContents(t, step, sim, want, rng.Uint64())
+				}
+			}
+			checkSameContents(t, -1, sim, want, 0)
+			if keySpace >= 100 && sim.B == 0 {
+				t.Errorf("%s/%d: the map never grew", name, keySpace)
+			}
+		}
+	}
+}
+
+// TestSimMapSameSizeGrow fills one bucket at a time past its 8 slots and empties it again.
+// The map never holds enough entries to double, but the overflow buckets left behind pile
+// up until the map grows to the same size to get rid of them.
+func TestSimMapSameSizeGrow(t *testing.T) {
+	m := newSimMap[int, int](40, 1)
+	m.hasher = func(k int, seed uint64) uint64 { return uint64(k)<<56 | uint64(k/100) }
+	if m.B != 3 {
+		t.Fatalf("make with hint 40 gave B=%d, want 3", m.B)
+	}
+	var grows []string
+	m.onStep = func(s mapStep) {
+		if s.op == "grow" {
+			grows = append(grows, s.note)
+		}
+	}
+	for round := 0; round < 8; round++ {
+		for k := round * 100; k < round*100+20; k++ {
+			m.set(k, k)
+		}
+		for k := round * 100; k < round*100+20; k++ {
+			m.remove(k)
+		}
+	}
+	if len(grows) == 0 || grows[0] != "too many overflow buckets: same-size grow to 8 buckets" {
+		t.Fatalf("grows = %q, want a same-size grow first", grows)
+	}
+	if m.B != 3 || m.len() != 0 {
+		t.Errorf("B=%d len=%d after the churn, want 3 and 0", m.B, m.len())
+	}
+}
+
+// TestSimMapBucketPlacement checks that after growing every entry sits in the bucket its hash selects.
+func TestSimMapBucketPlacement(t *testing.T) {
+	m := newSimMap[int, int](0, 7)
+	for i := 0; i < 1000; i++ {
+		m.set(i, i)
+	}
+	for m.growing() {
+		m.set(0, 0) // each write evacuates more of the old buckets
+	}
+	for i, b := range m.buckets {
+		for ; b != nil; b = b.overflow {
+			for j := 0; j < bucketCnt; j++ {
+				if isEmpty(b.tophash[j]) {
+					continue
+				}
+				h := m.hash(b.keys[j])
+				if int(h&m.bucketMask()) != i || tophash(h) != b.tophash[j] {
+					t.Errorf("key %d in bucket %d with tophash %02x; its hash picks bucket %d, tophash %02x",
+						b.keys[j], i, b.tophash[j], h&m.bucketMask(), tophash(h))
+				}
+			}
+		}
+	}
+}
+
+// TestSimMapIterationOrder checks that different starting points give different orders of the same keys.
+func TestSimMapIterationOrder(t *testing.T) {
+	m := newSimMap[int, int](0, 1)
+	for i := 0; i < 50; i++ {
+		m.set(i, i)
+	}
+	first := m.keys(0)
+	second := m.keys(5<<m.B | 3)
+	same := true
+	for i := range first {
+		if first[i] != second[i] {
+			same = false
+		}
+	}
+	if same {
+		t.Errorf("iteration order did not depend on the starting point: %v", first)
+	}
+	sort.Ints(first)
+	sort.Ints(second)
+	for i := range first {
+		if first[i] != i || second[i] != i {
+			t.Fatalf("iteration missed or repeated keys: %v / %v", first, second)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// run --memstats measures what an example did to the heap.
+/* The notes in main.go describe the heap, the stack and the collector; this puts numbers
+on them. runtime.MemStats is read before and after each example and the differences are
+reported: bytes and objects allocated, how far the live heap grew, and how many GC cycles
+ran with how long they stopped the world.
+
+	go-concepts run --memstats parallel-reduce
+	go-concepts run --all --memstats-json mem.json
+
+Allocations are counted for the whole process, so goroutines that outlive an example are
+charged to the next one. The collector is not forced between examples: heap growth is
+what the example left for the next cycle to collect, not only what it still uses.
+The JSON report holds one object per example, for comparing runs over time.
+*/
+type memReport struct {
+	Example       string        `json:"example"`
+	Wall          time.Duration `json:"wall_ns"`
+	AllocBytes    uint64        `json:"alloc_bytes"` // bytes allocated, freed or not
+	Mallocs       uint64        `json:"mallocs"`
+	Frees         uint64        `json:"frees"`
+	HeapBefore    uint64        `json:"heap_alloc_before"`
+	HeapAfter     uint64        `json:"heap_alloc_after"`
+	HeapGrowth    int64         `json:"heap_growth"` // negative when a GC freed more than the example left
+	HeapSysGrowth int64         `json:"heap_sys_growth"`
+	GCCycles      uint32        `json:"gc_cycles"`
+	GCPauseTotal  time.Duration `json:"gc_pause_total_ns"`
+	GCPauseMax    time.Duration `json:"gc_pause_max_ns"`
+}
+
+// measureMemory runs run and reports the change in the runtime's memory statistics.
+func measureMemory(name string, run func()) memReport {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	run()
+	wall := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return memReport{
+		Example:       name,
+		Wall:          wall,
+		AllocBytes:    after.TotalAlloc - before.TotalAlloc,
+		Mallocs:       after.Mallocs - before.Mallocs,
+		Frees:         after.Frees - before.Frees,
+		HeapBefore:    before.HeapAlloc,
+		HeapAfter:     after.HeapAlloc,
+		HeapGrowth:    int64(after.HeapAlloc) - int64(before.HeapAlloc),
+		HeapSysGrowth: int64(after.HeapSys) - int64(before.HeapSys),
+		GCCycles:      after.NumGC - before.NumGC,
+		GCPauseTotal:  time.Duration(after.PauseTotalNs - before.PauseTotalNs),
+		GCPauseMax:    gcPauseMax(&before, &after),
+	}
+}
+
+// gcPauseMax returns the longest stop-the-world pause of the GC cycles between two reads.
+// MemStats keeps only the last 256 pauses, so older ones of a long run are not seen.
+func gcPauseMax(before, after *runtime.MemStats) time.Duration {
+	var longest time.Duration
+	n := uint32(len(after.PauseNs))
+	first := before.NumGC
+	if after.NumGC-first > n {
+		first = after.NumGC - n
+	}
+	for i := first; i < after.NumGC; i++ {
+		if p := time.Duration(after.PauseNs[i%n]); p > longest {
+			longest = p
+		}
+	}
+	return longest
+}
+
+// print writes the report as a short table.
+func (r memReport) print(w io.Writer) {
+	fmt.Fprintf(w, "memstats for %s (%v)\n", r.Example, r.Wall.Round(time.Millisecond))
+	fmt.Fprintf(w, "  %-14s  %s in %d objects, %d freed\n", "allocated", formatBytes(int64(r.AllocBytes)), r.Mallocs, r.Frees)
+	fmt.Fprintf(w, "  %-14s  %s -> %s (%s)\n", "live heap", formatBytes(int64(r.HeapBefore)), formatBytes(int64(r.HeapAfter)), signedBytes(r.HeapGrowth))
+	fmt.Fprintf(w, "  %-14s  %s\n", "heap from OS", signedBytes(r.HeapSysGrowth))
+	fmt.Fprintf(w, "  %-14s  %d, pauses %v total, %v max\n", "GC cycles", r.GCCycles, r.GCPauseTotal, r.GCPauseMax)
+}
+
+// formatBytes writes n in B, KiB or MiB.
+func formatBytes(n int64) string {
+	switch {
+	case n < 0:
+		return "-" + formatBytes(-n)
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	}
+}
+
+func signedBytes(n int64) string {
+	if n >= 0 {
+		return "+" + formatBytes(n)
+	}
+	return formatBytes(n)
+}
+
+// writeMemJSON writes the reports as a JSON array.
+func writeMemJSON(w io.Writer, reports []memReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if reports == nil {
+		reports = []memReport{}
+	}
+	return enc.Encode(reports)
+}
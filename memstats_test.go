@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var memSink [][]byte
+
+func TestMeasureMemory(t *testing.T) {
+	r := measureMemory("alloc", func() {
+		for i := 0; i < 100; i++ {
+			memSink = append(memSink, make([]byte, 1<<10))
+		}
+		runtime.GC()
+	})
+	memSink = nil
+
+	if r.AllocBytes < 100<<10 {
+		t.Errorf("AllocBytes = %d, want at least %d", r.AllocBytes, 100<<10)
+	}
+	if r.Mallocs < 100 {
+		t.Errorf("Mallocs = %d, want at least 100", r.Mallocs)
+	}
+	if r.GCCycles < 1 {
+		t.Errorf("GCCycles = %d, want at least 1 after runtime.GC", r.GCCycles)
+	}
+	if r.GCPauseMax > r.GCPauseTotal {
+		t.Errorf("longest pause %v exceeds the total %v", r.GCPauseMax, r.GCPauseTotal)
+	}
+
+	var text bytes.Buffer
+	r.print(&text)
+	for _, want := range []string{"memstats for alloc", "allocated", "live heap", "GC cycles"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, text.String())
+		}
+	}
+}
+
+func TestGCPauseMax(t *testing.T) {
+	var before, after runtime.MemStats
+	before.NumGC = 3
+	after.NumGC = 5
+	after.PauseNs[2] = 900 // cycle 3, before the first read
+	after.PauseNs[3] = 100
+	after.PauseNs[4] = 400
+	if got := gcPauseMax(&before, &after); got != 400 {
+		t.Errorf("gcPauseMax = %v, want 400ns", got)
+	}
+
+	// More cycles than PauseNs remembers: every slot is one of the new cycles.
+	after.NumGC = before.NumGC + 300
+	if got := gcPauseMax(&before, &after); got != 900 {
+		t.Errorf("gcPauseMax over a wrapped buffer = %v, want 900ns", got)
+	}
+}
+
+func TestMemJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeMemJSON(&out, nil); err != nil || strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("no reports = %q, %v; want []", out.String(), err)
+	}
+
+	out.Reset()
+	want := []memReport{{Example: "sync", Wall: time.Millisecond, AllocBytes: 2048, HeapGrowth: -512, GCCycles: 1}}
+	if err := writeMemJSON(&out, want); err != nil {
+		t.Fatal(err)
+	}
+	var got []memReport
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+	if !strings.Contains(out.String(), `"heap_growth": -512`) {
+		t.Errorf("JSON is missing heap_growth:\n%s", out.String())
+	}
+}
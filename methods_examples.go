@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Vertex is the struct of the Methods section of methods_interfaces.go: Abs has a
+// value receiver, Scale a pointer receiver, because it modifies the vertex.
+type Vertex struct {
+	X, Y float64
+}
+
+func (v Vertex) Abs() float64 { return math.Sqrt(v.X*v.X + v.Y*v.Y) }
+
+func (v *Vertex) Scale(f float64) {
+	v.X *= f
+	v.Y *= f
+}
+
+// MyFloat shows that methods can be declared on any type defined in the package.
+type MyFloat float64
+
+func (f MyFloat) Abs() float64 {
+	if f < 0 {
+		return float64(-f)
+	}
+	return float64(f)
+}
+
+// Abser is satisfied by Vertex, *Vertex, MyFloat and *MyFloat.
+type Abser interface {
+	Abs() float64
+}
+
+// Scaler is satisfied by *Vertex only: Scale is not in the method set of Vertex.
+type Scaler interface {
+	Scale(f float64)
+}
+
+// AbsScaler needs both methods, so it is satisfied by *Vertex only too.
+type AbsScaler interface {
+	Abser
+	Scaler
+}
+
+// methodSetsExample calls value and pointer methods on values and pointers, then
+// shows which of them the interfaces accept, as reflect sees their method sets.
+func methodSetsExample(ec *exampleContext) {
+	v := Vertex{3, 4}
+	ec.println("Calling methods")
+	ec.println("  v.Abs():", v.Abs())
+	v.Scale(10) // v is addressable, so the compiler calls (&v).Scale(10)
+	ec.println("  after v.Scale(10), which means (&v).Scale(10):", v)
+	p := &v
+	ec.println("  p.Abs(), which means (*p).Abs():", p.Abs())
+	f := MyFloat(-math.Sqrt2)
+	ec.println("  MyFloat(-√2).Abs():", f.Abs())
+
+	ec.println("Assigning to interfaces")
+	var a Abser = f
+	ec.println("  var a Abser = f       ", a.Abs())
+	a = v
+	ec.println("  a = v                 ", a.Abs())
+	a = &v
+	ec.println("  a = &v                ", a.Abs())
+	var s Scaler = &v
+	s.Scale(0.5)
+	ec.println("  var s Scaler = &v; s.Scale(0.5):", v)
+	ec.println("  var s Scaler = v does not compile:")
+	ec.println("    Vertex does not implement Scaler (method Scale has pointer receiver)")
+	ec.println("  An interface holds a copy of v, which is not addressable, so Scale could not modify it")
+
+	ec.println("Method sets, as reflect reports them")
+	scaler := reflect.TypeOf((*Scaler)(nil)).Elem()
+	for _, t := range []reflect.Type{reflect.TypeOf(v), reflect.TypeOf(&v), reflect.TypeOf(f), reflect.TypeOf(&f)} {
+		names := make([]string, t.NumMethod())
+		for i := range names {
+			names[i] = t.Method(i).Name
+		}
+		ec.println(fmt.Sprintf("  %-14s %-12s implements Scaler: %v", t.String(), fmt.Sprint(names), t.Implements(scaler)))
+	}
+
+	m := map[string]Vertex{"origin": {}}
+	ec.println("Map elements are not addressable: m[\"origin\"].Scale(2) does not compile, m[\"origin\"].Abs() does:", m["origin"].Abs())
+	ec.println(`Run "go-concepts methods methods_examples.go Vertex" for the report go/types gives`)
+}
@@ -19,6 +19,10 @@ You cannot declare a method with a receiver whose type is defined in another pac
 You can declare methods with pointer receivers.
 This means the receiver type has the literal syntax *T for some type T. (Also, T cannot itself be a pointer such as *int.)
 Methods with pointer receivers can modify the value to which the receiver points. Since methods often need to modify their receiver, pointer receivers are more common than value receivers.
+
+Vertex and MyFloat are declared in methods_examples.go: "go-concepts run method-sets".
+"go-concepts methods Vertex" lists the method sets of Vertex and *Vertex and the
+interfaces each one satisfies.
 */
 
 /* Interfaces
@@ -43,6 +47,9 @@ The empty interface
 The interface type that specifies zero methods is known as the empty interface:
 	interface{}
 An empty interface may hold values of any type.
+
+iface_internals.go shows the two words an interface value is made of:
+"go-concepts run iface-internals".
 */
 
 /* Type assertions
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// The method set of a type decides which interfaces its values can be assigned to.
+/* The method set of a named type T holds the methods declared with a value receiver
+(t T); the method set of *T holds those and also the ones declared with a pointer
+receiver (t *T). Methods promoted from embedded fields follow the same rule: a field
+S contributes S's method set, a field *S contributes *S's.
+
+	func (v Vertex) Abs() float64     // in the method sets of Vertex and *Vertex
+	func (v *Vertex) Scale(f float64) // in the method set of *Vertex only
+
+Calling v.Scale(2) still compiles when v is an addressable variable, because the
+compiler takes its address for you. An interface value holds a copy that cannot be
+addressed, so assigning a Vertex to an interface that needs Scale is an error:
+
+	var s Scaler = v // Vertex does not implement Scaler (method Scale has pointer receiver)
+
+go-concepts methods lists both method sets of a type, using go/types, and every
+interface of the package that T or *T satisfies, explaining why the other does not:
+
+	go-concepts methods methods_examples.go Vertex MyFloat
+	go-concepts methods -all
+*/
+type methodSetReport struct {
+	name         string // T, qualified relative to its package
+	underlying   string
+	isIface      bool
+	value        []methodEntry // method set of T
+	pointer      []methodEntry // method set of *T
+	ifaces       []ifaceMatch  // interfaces T or *T satisfy
+	implementers []string      // for an interface T, the types of the package implementing it
+}
+
+type methodEntry struct {
+	signature string // Name(params) results
+	note      string // receiver kind and where a promoted method comes from
+}
+
+// ifaceMatch records whether T and *T implement one interface, or why not.
+type ifaceMatch struct {
+	name          string
+	byValue       bool
+	byPointer     bool
+	valueReason   string // why T does not implement it, when it doesn't
+	pointerReason string
+}
+
+// methodSetsOf builds the report for tn against the interfaces declared in pkg.
+func methodSetsOf(pkg *types.Package, tn *types.TypeName, candidates []*types.TypeName) methodSetReport {
+	qf := types.RelativeTo(pkg)
+	T := tn.Type()
+	ptr := types.NewPointer(T)
+	r := methodSetReport{
+		name:       types.TypeString(T, qf),
+		underlying: types.TypeString(T.Underlying(), qf),
+		value:      methodEntries(T, qf),
+	}
+	if it, ok := T.Underlying().(*types.Interface); ok {
+		r.isIface = true
+		for _, c := range candidates {
+			if c == tn || isInterface(c.Type()) || !it.IsMethodSet() {
+				continue
+			}
+			for _, t := range []types.Type{c.Type(), types.NewPointer(c.Type())} {
+				if types.Implements(t, it) {
+					r.implementers = append(r.implementers, types.TypeString(t, qf))
+				}
+			}
+		}
+		return r
+	}
+	r.pointer = methodEntries(ptr, qf)
+
+	for _, c := range candidates {
+		it, ok := c.Type().Underlying().(*types.Interface)
+		if !ok || c == tn || it.NumMethods() == 0 || !it.IsMethodSet() {
+			continue
+		}
+		m := ifaceMatch{
+			name:      types.TypeString(c.Type(), qf),
+			byValue:   types.Implements(T, it),
+			byPointer: types.Implements(ptr, it),
+		}
+		if !m.byValue {
+			m.valueReason = notImplementedReason(T, c.Type(), it, qf)
+		}
+		if !m.byPointer {
+			m.pointerReason = notImplementedReason(ptr, c.Type(), it, qf)
+		}
+		// An interface the type has nothing in common with is not worth listing; one
+		// whose method it declares with the wrong signature is.
+		if m.byValue || m.byPointer || strings.Contains(m.pointerReason, "wrong type") {
+			r.ifaces = append(r.ifaces, m)
+		}
+	}
+	return r
+}
+
+func isInterface(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Interface)
+	return ok
+}
+
+// methodEntries lists the method set of t in the order go/types sorts it, by name.
+func methodEntries(t types.Type, qf types.Qualifier) []methodEntry {
+	ms := types.NewMethodSet(t)
+	entries := make([]methodEntry, ms.Len())
+	for i := range entries {
+		sel := ms.At(i)
+		f := sel.Obj().(*types.Func)
+		e := methodEntry{signature: methodSignature(f, qf)}
+		if recv := f.Type().(*types.Signature).Recv(); recv != nil && !isInterface(recv.Type()) {
+			if _, ok := recv.Type().(*types.Pointer); ok {
+				e.note = "pointer receiver"
+			} else {
+				e.note = "value receiver"
+			}
+		}
+		if len(sel.Index()) > 1 {
+			e.note += ", promoted from " + embeddedPath(t, sel.Index())
+		}
+		entries[i] = e
+	}
+	return entries
+}
+
+// embeddedPath names the embedded fields a promoted method is found through.
+func embeddedPath(t types.Type, index []int) string {
+	var names []string
+	for _, i := range index[:len(index)-1] {
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		s, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		f := s.Field(i)
+		names = append(names, f.Name())
+		t = f.Type()
+	}
+	return strings.Join(names, ".")
+}
+
+// notImplementedReason explains why t does not implement it, worded like the compiler.
+func notImplementedReason(t, named types.Type, it *types.Interface, qf types.Qualifier) string {
+	missing, _ := types.MissingMethod(t, it, true)
+	if missing == nil {
+		return ""
+	}
+	prefix := fmt.Sprintf("%s does not implement %s", types.TypeString(t, qf), types.TypeString(named, qf))
+	if _, isPtr := t.(*types.Pointer); !isPtr {
+		sel := types.NewMethodSet(types.NewPointer(t)).Lookup(missing.Pkg(), missing.Name())
+		if sel != nil && types.Identical(sel.Type(), missing.Type()) {
+			return fmt.Sprintf("%s (method %s has pointer receiver)", prefix, missing.Name())
+		}
+	}
+	if sel := types.NewMethodSet(t).Lookup(missing.Pkg(), missing.Name()); sel != nil {
+		return fmt.Sprintf("%s (wrong type for method %s: have %s, want %s)", prefix, missing.Name(),
+			methodSignature(sel.Obj().(*types.Func), qf), methodSignature(missing, qf))
+	}
+	return fmt.Sprintf("%s (missing method %s)", prefix, missing.Name())
+}
+
+func methodSignature(f *types.Func, qf types.Qualifier) string {
+	return f.Name() + strings.TrimPrefix(types.TypeString(f.Type(), qf), "func")
+}
+
+// writeMethodSets prints r: the two method sets, then the interfaces.
+func writeMethodSets(w io.Writer, r methodSetReport) {
+	fmt.Fprintf(w, "%s  %s\n", r.name, r.underlying)
+	writeEntries := func(title string, entries []methodEntry) {
+		fmt.Fprintf(w, "  method set of %s:", title)
+		if len(entries) == 0 {
+			fmt.Fprintln(w, " empty")
+			return
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, e := range entries {
+			if e.note == "" {
+				fmt.Fprintf(tw, "    %s\n", e.signature)
+			} else {
+				fmt.Fprintf(tw, "    %s\t%s\n", e.signature, e.note)
+			}
+		}
+		tw.Flush()
+	}
+	writeEntries(r.name, r.value)
+	if r.isIface {
+		fmt.Fprintf(w, "  method set of *%s: empty, a pointer to an interface has no methods\n", r.name)
+		if len(r.implementers) == 0 {
+			fmt.Fprintln(w, "  implemented by no type of the package")
+		} else {
+			fmt.Fprintln(w, "  implemented by", strings.Join(r.implementers, ", "))
+		}
+		return
+	}
+	writeEntries("*"+r.name, r.pointer)
+	if len(r.ifaces) == 0 {
+		fmt.Fprintln(w, "  satisfies no interface of the package")
+		return
+	}
+	fmt.Fprintln(w, "  interfaces:")
+	for _, m := range r.ifaces {
+		switch {
+		case m.byValue && m.byPointer:
+			fmt.Fprintf(w, "    %s: satisfied by %s and *%s\n", m.name, r.name, r.name)
+		case m.byPointer:
+			fmt.Fprintf(w, "    %s: satisfied by *%s only\n      %s\n", m.name, r.name, m.valueReason)
+		case m.byValue:
+			// Only possible when T's method set has a method *T's lacks, which the language rules out.
+			fmt.Fprintf(w, "    %s: satisfied by %s only\n      %s\n", m.name, r.name, m.pointerReason)
+		default:
+			fmt.Fprintf(w, "    %s: not satisfied\n      %s\n", m.name, m.pointerReason)
+		}
+	}
+}
+
+// methodsCommand implements "go-concepts methods".
+func methodsCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("methods", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", ".", "directory of the package to load")
+	all := fs.Bool("all", false, "report every named type in the package that has methods")
+	names, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	path := *dir
+	if len(names) > 0 && strings.HasSuffix(names[0], ".go") {
+		path, names = names[0], names[1:]
+	}
+	if len(names) == 0 && !*all && path == *dir {
+		fmt.Fprintln(stderr, "usage: go-concepts methods [-dir dir] [file.go] <type>... | -all")
+		return 2
+	}
+
+	lp, err := loadPackage(path)
+	if err != nil {
+		fmt.Fprintln(stderr, "methods:", err)
+		return 1
+	}
+	var candidates []*types.TypeName
+	for _, tn := range lp.namedTypes() {
+		if !isGeneric(tn) {
+			candidates = append(candidates, tn)
+		}
+	}
+	var targets []*types.TypeName
+	if len(names) == 0 {
+		for _, tn := range candidates {
+			if types.NewMethodSet(types.NewPointer(tn.Type())).Len() > 0 && !isInterface(tn.Type()) {
+				targets = append(targets, tn)
+			}
+		}
+	}
+	for _, name := range names {
+		tn, err := lp.lookupType(name)
+		if err != nil {
+			fmt.Fprintln(stderr, "methods:", err)
+			return 1
+		}
+		if isGeneric(tn) {
+			fmt.Fprintf(stderr, "methods: %s is generic; its method sets depend on the type arguments\n", name)
+			return 1
+		}
+		targets = append(targets, tn)
+	}
+
+	for i, tn := range targets {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		writeMethodSets(stdout, methodSetsOf(lp.pkg, tn, candidates))
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const methodSetsSource = `package shapes
+
+type Sizer interface{ Size() int }
+type Grower interface{ Grow(n int) }
+type Namer interface{ Name() string }
+
+type Box struct{ w int }
+
+func (b Box) Size() int   { return b.w }
+func (b *Box) Grow(n int) { b.w += n }
+func (b Box) Name() int   { return 0 }
+
+// Crate embeds *Box, so Crate's method set has Box's pointer methods too.
+type Crate struct{ *Box }
+
+type Bag struct{ Box }
+`
+
+// TestMethodSets checks both method sets and every reason on a package with no
+// imports, which type-checks quickly.
+func TestMethodSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shapes.go")
+	if err := os.WriteFile(path, []byte(methodSetsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := methodsCommand([]string{path, "Box", "Crate", "Bag", "Grower"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	reports := strings.Split(stdout.String(), "\n\n")
+	if len(reports) != 4 {
+		t.Fatalf("got %d reports, want 4:\n%s", len(reports), stdout.String())
+	}
+	for i, wants := range [][]string{
+		{
+			"method set of Box:\n    Name() int  value receiver\n    Size() int  value receiver\n  method set of *Box:",
+			"Grow(n int)  pointer receiver",
+			"Grower: satisfied by *Box only\n      Box does not implement Grower (method Grow has pointer receiver)",
+			"Namer: not satisfied\n      *Box does not implement Namer (wrong type for method Name: have Name() int, want Name() string)",
+			"Sizer: satisfied by Box and *Box",
+		},
+		{
+			"method set of Crate:\n    Grow(n int)  pointer receiver, promoted from Box",
+			"Grower: satisfied by Crate and *Crate",
+		},
+		{
+			"Bag does not implement Grower (method Grow has pointer receiver)",
+			"Grow(n int)  pointer receiver, promoted from Box",
+		},
+		{
+			"method set of *Grower: empty",
+			"implemented by *Bag, *Box, Crate, *Crate",
+		},
+	} {
+		for _, want := range wants {
+			if !strings.Contains(reports[i], want) {
+				t.Errorf("report %d is missing %q:\n%s", i, want, reports[i])
+			}
+		}
+	}
+
+	stdout.Reset()
+	if code := methodsCommand([]string{path, "Missing"}, &stdout, &stderr); code != 1 {
+		t.Errorf("unknown type: exit code %d, want 1", code)
+	}
+}
+
+// TestMethodsCommand reports on the Vertex of methods_examples.go, which imports
+// packages type-checked from source.
+func TestMethodsCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type-checks the standard library from source")
+	}
+	var stdout, stderr bytes.Buffer
+	if code := methodsCommand([]string{"methods_examples.go", "Vertex"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"Abser: satisfied by Vertex and *Vertex",
+		"Scaler: satisfied by *Vertex only\n      Vertex does not implement Scaler (method Scale has pointer receiver)",
+		"AbsScaler: satisfied by *Vertex only",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, stdout.String())
+		}
+	}
+}
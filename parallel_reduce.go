@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Parallel reduce generalises the sumMembers pattern from channelExample to any slice and any number of goroutines.
+/* channelExample splits its slice into two halves, sums each half in its own goroutine and
+receives both partial sums from one channel. parallelReduce does the same with N chunks:
+
+	sum, err := parallelReduce(ctx, values, byChunks(4), func(a, b int) int { return a + b })
+
+Each chunk is folded by its own goroutine, which sends its partial result, tagged with the
+chunk index, on a shared channel. The partial results arrive in whatever order the goroutines
+finish, so they are stored by index and combined left to right afterwards. That keeps the
+result deterministic for any associative combine function, even one that is not commutative
+such as string concatenation.
+*/
+type chunking struct {
+	count int
+	size  int
+}
+
+// byChunks splits the input into n chunks of nearly equal size.
+func byChunks(n int) chunking { return chunking{count: n} }
+
+// bySize splits the input into chunks of n items; the last chunk may be shorter.
+func bySize(n int) chunking { return chunking{size: n} }
+
+// bounds returns the [start, end) ranges of the chunks for n items.
+func (c chunking) bounds(n int) ([][2]int, error) {
+	size := c.size
+	switch {
+	case c.count > 0 && c.size > 0:
+		return nil, errors.New("set either a chunk count or a chunk size, not both")
+	case c.count > 0:
+		size = (n + c.count - 1) / c.count
+	case c.size <= 0:
+		return nil, errors.New("chunk count or chunk size must be positive")
+	}
+	if size == 0 {
+		size = 1
+	}
+	var b [][2]int
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		b = append(b, [2]int{start, end})
+	}
+	return b, nil
+}
+
+type partialResult[T any] struct {
+	index int
+	value T
+	err   error
+}
+
+// reduceCheckEvery is how many items a chunk folds between checks for cancellation.
+const reduceCheckEvery = 4096
+
+// reduceChunk folds chunk with combine and sends the result on out, like sumMembers does with count.
+func reduceChunk[T any](ctx context.Context, index int, chunk []T, combine func(a, b T) T, out chan<- partialResult[T]) {
+	acc := chunk[0]
+	for i, v := range chunk[1:] {
+		if i%reduceCheckEvery == 0 && ctx.Err() != nil {
+			out <- partialResult[T]{index: index, err: ctx.Err()}
+			return
+		}
+		acc = combine(acc, v)
+	}
+	out <- partialResult[T]{index: index, value: acc}
+}
+
+// parallelReduce folds items with combine, one goroutine per chunk, and returns the zero value
+// for an empty slice. combine must be associative. It returns ctx.Err() if ctx is done first.
+func parallelReduce[T any](ctx context.Context, items []T, split chunking, combine func(a, b T) T) (T, error) {
+	var zero T
+	bounds, err := split.bounds(len(items))
+	if err != nil || len(bounds) == 0 {
+		return zero, err
+	}
+
+	// Buffered, so workers never block on a send once the caller has given up.
+	out := make(chan partialResult[T], len(bounds))
+	for i, b := range bounds {
+		go reduceChunk(ctx, i, items[b[0]:b[1]], combine, out)
+	}
+
+	partials := make([]T, len(bounds))
+	for range bounds {
+		select {
+		case r := <-out:
+			if r.err != nil {
+				return zero, r.err
+			}
+			partials[r.index] = r.value
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+
+	acc := partials[0]
+	for _, p := range partials[1:] {
+		acc = combine(acc, p)
+	}
+	return acc, nil
+}
+
+// sequentialReduce is the plain loop parallelReduce is measured against.
+func sequentialReduce[T any](items []T, combine func(a, b T) T) T {
+	var acc T
+	if len(items) == 0 {
+		return acc
+	}
+	acc = items[0]
+	for _, v := range items[1:] {
+		acc = combine(acc, v)
+	}
+	return acc
+}
+
+func parallelReduceExample(ec *exampleContext) {
+	ctx := context.Background()
+	ec.println("Parallel Reduce Example")
+
+	slice := []int{7, 9, 4, -11, 1, 0}
+	sum, _ := parallelReduce(ctx, slice, byChunks(2), func(a, b int) int { return a + b })
+	ec.println("Sum of", slice, "in 2 chunks:", sum)
+
+	values := make([]int, 1000)
+	for i := range values {
+		values[i] = i + 1
+	}
+	sum, _ = parallelReduce(ctx, values, byChunks(8), func(a, b int) int { return a + b })
+	ec.println("Sum of 1..1000 in 8 chunks:", sum)
+	largest, _ := parallelReduce(ctx, values, bySize(100), func(a, b int) int {
+		if a > b {
+			return a
+		}
+		return b
+	})
+	ec.println("Max of 1..1000 in chunks of 100:", largest)
+
+	// Concatenation is associative but not commutative: the chunk order must be kept.
+	words := strings.Fields("the quick brown fox jumps over the lazy dog")
+	sentence, _ := parallelReduce(ctx, words, bySize(2), func(a, b string) string { return a + " " + b })
+	ec.println("Words joined in chunks of 2:", sentence)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := parallelReduce(cancelled, values, byChunks(4), func(a, b int) int { return a + b })
+	ec.println("Reduce with a cancelled context:", err)
+
+	_, err = parallelReduce(ctx, values, byChunks(0), func(a, b int) int { return a + b })
+	ec.println("Reduce with 0 chunks:", err)
+}
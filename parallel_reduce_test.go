@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func add(a, b int) int { return a + b }
+
+func TestChunkingBounds(t *testing.T) {
+	tests := []struct {
+		split chunking
+		n     int
+		want  string
+	}{
+		{byChunks(2), 6, "[[0 3] [3 6]]"},
+		{byChunks(4), 10, "[[0 3] [3 6] [6 9] [9 10]]"},
+		{byChunks(8), 3, "[[0 1] [1 2] [2 3]]"},
+		{bySize(4), 10, "[[0 4] [4 8] [8 10]]"},
+		{bySize(4), 0, "[]"},
+	}
+	for _, tt := range tests {
+		b, err := tt.split.bounds(tt.n)
+		if err != nil {
+			t.Fatalf("%+v.bounds(%d): %v", tt.split, tt.n, err)
+		}
+		if got := fmt.Sprint(b); got != tt.want {
+			t.Errorf("%+v.bounds(%d) = %s, want %s", tt.split, tt.n, got, tt.want)
+		}
+	}
+
+	for _, bad := range []chunking{byChunks(0), bySize(-1), {count: 2, size: 2}} {
+		if _, err := bad.bounds(10); err == nil {
+			t.Errorf("%+v.bounds(10) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestParallelReduceMatchesSequential(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 200; n += 7 {
+		items := make([]int, n)
+		for i := range items {
+			items[i] = rng.Intn(1000) - 500
+		}
+		want := sequentialReduce(items, add)
+		for _, split := range []chunking{byChunks(1), byChunks(3), byChunks(16), bySize(1), bySize(10)} {
+			got, err := parallelReduce(context.Background(), items, split, add)
+			if err != nil || got != want {
+				t.Errorf("n=%d %+v: got %d, %v; want %d", n, split, got, err, want)
+			}
+		}
+	}
+}
+
+// TestParallelReduceKeepsChunkOrder uses a non-commutative combine function,
+// so any reordering of the partial results shows up in the result.
+func TestParallelReduceKeepsChunkOrder(t *testing.T) {
+	items := make([]string, 100)
+	for i := range items {
+		items[i] = strconv.Itoa(i)
+	}
+	concat := func(a, b string) string { return a + "," + b }
+	want := sequentialReduce(items, concat)
+	for i := 0; i < 50; i++ {
+		got, err := parallelReduce(context.Background(), items, byChunks(7), concat)
+		if err != nil || got != want {
+			t.Fatalf("run %d: got %q, %v; want %q", i, got, err, want)
+		}
+	}
+}
+
+func TestParallelReduceCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	items := make([]int, 1000)
+	if _, err := parallelReduce(ctx, items, byChunks(4), add); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+// benchProcs returns the GOMAXPROCS values to benchmark: 1, 2, 4 and the number of CPUs.
+func benchProcs() []int {
+	procs := []int{1, 2, 4}
+	if n := runtime.NumCPU(); n > 4 {
+		procs = append(procs, n)
+	}
+	return procs
+}
+
+func BenchmarkReduce(b *testing.B) {
+	for _, n := range []int{1_000, 100_000, 10_000_000} {
+		items := make([]int, n)
+		for i := range items {
+			items[i] = i
+		}
+		b.Run(fmt.Sprintf("n=%d/sequential", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sequentialReduce(items, add)
+			}
+		})
+		for _, procs := range benchProcs() {
+			b.Run(fmt.Sprintf("n=%d/parallel/procs=%d", n, procs), func(b *testing.B) {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+				for i := 0; i < b.N; i++ {
+					if _, err := parallelReduce(context.Background(), items, byChunks(procs), add); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A pipeline is a series of stages connected by channels, where each stage is a group of goroutines running the same function.
+/* In each stage, the goroutines
+  - receive values from upstream via inbound channels
+  - perform some function on that data, usually producing new values
+  - send values downstream via outbound channels
+
+rangeAndCloseChannel in channels.go is the smallest pipeline: the fibonacci generator
+and a consumer that ranges over its channel until it is closed. The functions below
+let stages be chained:
+
+	p := newPipeline(ctx)
+	evens := pipeFilter(pipeGenerate(p, fibonacci), isEven)
+	first, err := pipeCollect(pipeTake(evens, 5))
+
+Every stage owns its output channel and closes it when it returns, so a range over the
+last stage ends once the whole pipeline is done. A stage stops when
+  - its input is closed: it has seen every value,
+  - a downstream stage stops reading, as pipeTake does after n values: stopping a stage
+    stops the one before it, and so on back to the source,
+  - any stage or sink returns an error, or the caller cancels ctx: every stage stops.
+
+Stages with more than one worker do not keep the order of their input.
+*/
+type pipeline struct {
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+func newPipeline(ctx context.Context) *pipeline {
+	p := &pipeline{parent: ctx}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	return p
+}
+
+// fail records the first error and stops every stage.
+func (p *pipeline) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// Wait blocks until every stage has returned. It returns the first error a stage
+// or sink reported, or the caller's ctx.Err() if ctx was cancelled.
+func (p *pipeline) Wait() error {
+	p.wg.Wait()
+	p.cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	return p.parent.Err()
+}
+
+// stream is the output of a stage: the channel to read and a way to tell the stage to stop.
+type stream[T any] struct {
+	p    *pipeline
+	c    <-chan T
+	stop context.CancelFunc
+}
+
+type stageConfig struct {
+	workers int
+	buffer  int
+	trace   *exampleContext // records the sends on and the close of the output, if set
+	channel string
+}
+
+type stageOption func(*stageConfig)
+
+// withWorkers runs a stage on n goroutines.
+func withWorkers(n int) stageOption { return func(c *stageConfig) { c.workers = n } }
+
+// withBuffer gives a stage an output channel with room for n values.
+func withBuffer(n int) stageOption { return func(c *stageConfig) { c.buffer = n } }
+
+// withTrace records every send on the stage's output channel, and its close, in ec's
+// channel trace under the given name. A send is recorded before it is attempted, so a
+// value the stage was stopped from sending is recorded too.
+func withTrace(ec *exampleContext, channel string) stageOption {
+	return func(c *stageConfig) { c.trace, c.channel = ec, channel }
+}
+
+// oneWorker returns opts with withWorkers(1) last, without writing into the caller's slice.
+func oneWorker(opts []stageOption) []stageOption {
+	return append(append([]stageOption(nil), opts...), withWorkers(1))
+}
+
+// startStage runs work on the configured number of goroutines. work returns when it is
+// done or when emit reports that the stage has been stopped. The output channel is
+// closed, and the upstream stage stopped, once every worker has returned.
+func startStage[T any](p *pipeline, opts []stageOption, stopUpstream func(), work func(ctx context.Context, emit func(T) bool) error) stream[T] {
+	cfg := stageConfig{workers: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.workers < 1 {
+		cfg.workers = 1
+	}
+
+	ctx, stop := context.WithCancel(p.ctx)
+	out := make(chan T, cfg.buffer)
+	emit := func(v T) bool {
+		if cfg.trace != nil {
+			cfg.trace.traceSend(cfg.channel, v)
+		}
+		select {
+		case out <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var workers sync.WaitGroup
+	workers.Add(cfg.workers)
+	p.wg.Add(1)
+	for i := 0; i < cfg.workers; i++ {
+		go func() {
+			defer workers.Done()
+			if err := work(ctx, emit); err != nil {
+				p.fail(err)
+			}
+		}()
+	}
+	go func() {
+		defer p.wg.Done()
+		workers.Wait()
+		if cfg.trace != nil {
+			cfg.trace.traceClose(cfg.channel)
+		}
+		close(out)
+		stop()
+		if stopUpstream != nil {
+			stopUpstream()
+		}
+	}()
+	return stream[T]{p: p, c: out, stop: stop}
+}
+
+// receive reads the next value of in, or reports false once in is closed or ctx is done.
+func receive[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// pipeGenerate starts a source stage. gen sends values with emit until it runs out
+// or emit returns false; its output is closed when it returns.
+func pipeGenerate[T any](p *pipeline, gen func(ctx context.Context, emit func(T) bool) error, opts ...stageOption) stream[T] {
+	return startStage(p, opts, nil, gen)
+}
+
+// pipeFrom starts a source stage that sends items in order.
+func pipeFrom[T any](p *pipeline, items ...T) stream[T] {
+	return pipeGenerate(p, func(ctx context.Context, emit func(T) bool) error {
+		for _, v := range items {
+			if !emit(v) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// pipeFlatMap sends every value f returns for each input value.
+func pipeFlatMap[T, U any](in stream[T], f func(T) ([]U, error), opts ...stageOption) stream[U] {
+	return startStage(in.p, opts, in.stop, func(ctx context.Context, emit func(U) bool) error {
+		for {
+			v, ok := receive(ctx, in.c)
+			if !ok {
+				return nil
+			}
+			us, err := f(v)
+			if err != nil {
+				return err
+			}
+			for _, u := range us {
+				if !emit(u) {
+					return nil
+				}
+			}
+		}
+	})
+}
+
+// pipeMap sends f(v) for each input value v.
+func pipeMap[T, U any](in stream[T], f func(T) (U, error), opts ...stageOption) stream[U] {
+	return pipeFlatMap(in, func(v T) ([]U, error) {
+		u, err := f(v)
+		if err != nil {
+			return nil, err
+		}
+		return []U{u}, nil
+	}, opts...)
+}
+
+// pipeFilter sends the input values keep returns true for.
+func pipeFilter[T any](in stream[T], keep func(T) bool, opts ...stageOption) stream[T] {
+	return pipeFlatMap(in, func(v T) ([]T, error) {
+		if keep(v) {
+			return []T{v}, nil
+		}
+		return nil, nil
+	}, opts...)
+}
+
+// pipeBatch groups the input into slices of size values. The last batch may be shorter.
+// It panics if size is not positive.
+func pipeBatch[T any](in stream[T], size int, opts ...stageOption) stream[[]T] {
+	if size <= 0 {
+		panic(fmt.Sprintf("pipeBatch: batch size %d must be positive", size))
+	}
+	// Batching keeps state between values, so it always runs on one worker.
+	opts = oneWorker(opts)
+	return startStage(in.p, opts, in.stop, func(ctx context.Context, emit func([]T) bool) error {
+		var batch []T
+		for {
+			v, ok := receive(ctx, in.c)
+			if !ok {
+				if len(batch) > 0 && ctx.Err() == nil {
+					emit(batch)
+				}
+				return nil
+			}
+			batch = append(batch, v)
+			if len(batch) == size {
+				if !emit(batch) {
+					return nil
+				}
+				batch = nil
+			}
+		}
+	})
+}
+
+// pipeTake sends the first n input values, then stops the stages before it.
+func pipeTake[T any](in stream[T], n int, opts ...stageOption) stream[T] {
+	opts = oneWorker(opts)
+	return startStage(in.p, opts, in.stop, func(ctx context.Context, emit func(T) bool) error {
+		for i := 0; i < n; i++ {
+			v, ok := receive(ctx, in.c)
+			if !ok || !emit(v) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// pipeForEach calls f for each value of in on the calling goroutine, then waits for
+// the pipeline. An error from f stops every stage.
+func pipeForEach[T any](in stream[T], f func(T) error) error {
+	for v := range in.c {
+		if err := f(v); err != nil {
+			in.p.fail(err)
+			break
+		}
+	}
+	return in.p.Wait()
+}
+
+// pipeCollect returns every value of in, in the order it arrived.
+func pipeCollect[T any](in stream[T]) ([]T, error) {
+	var out []T
+	err := pipeForEach(in, func(v T) error {
+		out = append(out, v)
+		return nil
+	})
+	return out, err
+}
+
+func pipelineExample(ec *exampleContext) {
+	ec.println("Pipeline Example")
+	ctx := context.Background()
+
+	p := newPipeline(ctx)
+	evens := pipeFilter(pipeGenerate(p, fibonacci), func(n int) bool { return n%2 == 0 })
+	first, err := pipeCollect(pipeTake(evens, 6))
+	ec.println("First even Fibonacci numbers:", first, err)
+
+	// Four workers square the numbers, so the results are sorted before printing.
+	p = newPipeline(ctx)
+	squares := pipeMap(pipeFrom(p, 1, 2, 3, 4, 5, 6, 7, 8), func(n int) (int, error) { return n * n, nil },
+		withWorkers(4), withBuffer(8))
+	all, err := pipeCollect(squares)
+	sort.Ints(all)
+	ec.println("Squares on 4 workers:", all, err)
+
+	p = newPipeline(ctx)
+	words := pipeFlatMap(pipeFrom(p, "select blocks until", "one of its cases can run"),
+		func(s string) ([]string, error) { return strings.Fields(s), nil })
+	err = pipeForEach(pipeBatch(words, 3), func(batch []string) error {
+		ec.println("Batch:", strings.Join(batch, " "))
+		return nil
+	})
+	ec.println("Batches done:", err)
+
+	p = newPipeline(ctx)
+	errNegative := errors.New("negative value")
+	checked := pipeMap(pipeFrom(p, 3, 2, -1, 4), func(n int) (int, error) {
+		if n < 0 {
+			return 0, fmt.Errorf("%w: %d", errNegative, n)
+		}
+		return n, nil
+	})
+	_, err = pipeCollect(checked)
+	ec.println("Pipeline with a failing stage:", err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	p = newPipeline(cancelled)
+	naturals := pipeGenerate(p, func(ctx context.Context, emit func(int) bool) error {
+		for i := 0; emit(i); i++ {
+		}
+		return nil
+	})
+	err = pipeForEach(naturals, func(n int) error {
+		if n == 100 {
+			cancel()
+		}
+		return nil
+	})
+	ec.println("Infinite source after cancel:", err)
+}
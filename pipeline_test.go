@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// naturals is an infinite source: it only returns once the pipeline stops it.
+func naturals(ctx context.Context, emit func(int) bool) error {
+	for i := 0; emit(i); i++ {
+	}
+	return nil
+}
+
+func TestPipelineStagesInOrder(t *testing.T) {
+	p := newPipeline(context.Background())
+	s := pipeMap(pipeFilter(pipeFrom(p, 1, 2, 3, 4, 5, 6, 7), func(n int) bool { return n%2 == 1 }),
+		func(n int) (string, error) { return fmt.Sprint(n * 10), nil })
+	got, err := pipeCollect(pipeBatch(s, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[[10 30 50] [70]]" {
+		t.Errorf("got %v, want [[10 30 50] [70]]", got)
+	}
+}
+
+// TestPipelineTakeStopsUpstream takes a few values from an infinite source behind
+// several stages. Wait only returns once every stage, the source included, has returned.
+func TestPipelineTakeStopsUpstream(t *testing.T) {
+	p := newPipeline(context.Background())
+	s := pipeMap(pipeGenerate(p, naturals, withBuffer(4)), func(n int) (int, error) { return n + 1, nil }, withWorkers(3))
+	got, err := pipeCollect(pipeTake(pipeFilter(s, func(int) bool { return true }), 5))
+	if err != nil || len(got) != 5 {
+		t.Fatalf("got %v, %v; want 5 values", got, err)
+	}
+}
+
+func TestPipelineErrorStopsEveryStage(t *testing.T) {
+	errBoom := errors.New("boom")
+	p := newPipeline(context.Background())
+	s := pipeMap(pipeGenerate(p, naturals), func(n int) (int, error) {
+		if n == 50 {
+			return 0, errBoom
+		}
+		return n, nil
+	}, withWorkers(4))
+	if _, err := pipeCollect(s); !errors.Is(err, errBoom) {
+		t.Errorf("got %v, want %v", err, errBoom)
+	}
+
+	p = newPipeline(context.Background())
+	err := pipeForEach(pipeGenerate(p, naturals), func(n int) error {
+		if n == 10 {
+			return errBoom
+		}
+		return nil
+	})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("sink error: got %v, want %v", err, errBoom)
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	p := newPipeline(ctx)
+	n := 0
+	err := pipeForEach(pipeBatch(pipeGenerate(p, naturals), 10), func([]int) error {
+		n++
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v after %d batches, want %v", err, n, context.DeadlineExceeded)
+	}
+}
+
+func TestPipelineWorkersRunConcurrently(t *testing.T) {
+	var running, peak int32
+	p := newPipeline(context.Background())
+	s := pipeMap(pipeFrom(p, 1, 2, 3, 4, 5, 6, 7, 8), func(n int) (int, error) {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return n, nil
+	}, withWorkers(4))
+	got, err := pipeCollect(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(got)
+	if fmt.Sprint(got) != "[1 2 3 4 5 6 7 8]" {
+		t.Errorf("got %v", got)
+	}
+	if peak < 2 {
+		t.Errorf("at most %d workers ran at once, want several", peak)
+	}
+}
+
+// TestPipelineBatchOptions checks that pipeBatch rejects an empty batch size and
+// leaves the options the caller passed untouched.
+func TestPipelineBatchOptions(t *testing.T) {
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("pipeBatch accepted a batch size of 0")
+			}
+		}()
+		p := newPipeline(context.Background())
+		defer p.Wait()
+		defer p.cancel()
+		pipeBatch(pipeFrom(p, 1), 0)
+	}()
+
+	opts := make([]stageOption, 1, 2)
+	opts[0] = withWorkers(4)
+	if _, err := pipeCollect(pipeBatch(pipeFrom(newPipeline(context.Background()), 1), 2, opts...)); err != nil {
+		t.Fatal(err)
+	}
+	var cfg stageConfig
+	for _, opt := range opts[:cap(opts)] {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.workers != 4 {
+		t.Errorf("pipeBatch wrote into the caller's options: workers = %d, want 4", cfg.workers)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// run --trace records what the runtime did while the examples ran.
+/* The execution trace is written with runtime/trace. Each example runs in a task named
+after it, and the steps of the larger examples are regions inside that task, so the
+browser tool can show them side by side with the goroutines and GC cycles:
+
+	go-concepts run --trace out.trace channels
+	go tool trace out.trace
+
+Opening the browser is not needed for the main figures. While an example runs, the block
+profile records every wait on a channel or lock with its duration, the goroutine count is
+sampled and the GC statistics are read before and after. The summary prints them:
+
+	trace summary for channels
+	  wall time               2.503s
+	  goroutines              10 started, peak 6
+	  blocked on channels     4.007s in 24 waits
+	    chan send         2.226ms    1x  main.selectExample.func2  channels.go:147
+	    chan receive         12µs    2x  main.channelExample  channels.go:44
+	    ...
+	  GC                      1 cycle, pauses 19.879µs total, 19.879µs max
+
+Waits are attributed to the innermost function of this program on the blocked stack.
+*/
+type traceSummary struct {
+	name              string
+	wall              time.Duration
+	goroutinesCreated int64 // -1 when the runtime does not report it
+	peakGoroutines    int
+	blocks            []blockSite
+	gcCycles          uint32
+	gcPauseTotal      time.Duration
+	gcPauseMax        time.Duration
+}
+
+// blockSite is the time goroutines spent blocked at one place in this program.
+type blockSite struct {
+	op       string // "chan receive", "chan send", "select" or "sync"
+	function string
+	location string
+	count    int64
+	delay    time.Duration
+}
+
+// onChannel reports whether the wait was a channel operation rather than a lock.
+func (b blockSite) onChannel() bool { return b.op != "sync" }
+
+// step runs f as a named region of the example's trace task.
+// Regions cost next to nothing when tracing is off.
+func (ec *exampleContext) step(name string, f func()) {
+	trace.WithRegion(ec.ctx, name, f)
+}
+
+// startTrace starts the execution trace and the block profile.
+func startTrace(w io.Writer) error {
+	runtime.SetBlockProfileRate(1)
+	return trace.Start(w)
+}
+
+// stopTrace stops what startTrace started.
+func stopTrace() {
+	trace.Stop()
+	runtime.SetBlockProfileRate(0)
+}
+
+// runTraced runs ex in a trace task named after it and summarizes what the runtime did.
+func runTraced(ex example, ec *exampleContext, run func()) traceSummary {
+	s := traceSummary{name: ex.name, goroutinesCreated: -1}
+	blocksBefore, _ := readBlockProfile()
+	createdBefore, haveCreated := goroutinesCreated()
+	var msBefore runtime.MemStats
+	runtime.ReadMemStats(&msBefore)
+
+	stop := make(chan struct{})
+	peak := make(chan int)
+	go func() { peak <- sampleGoroutines(stop) }()
+
+	ctx, task := trace.NewTask(context.Background(), ex.name)
+	ec.ctx = ctx
+	start := time.Now()
+	run()
+	s.wall = time.Since(start)
+	task.End()
+	ec.ctx = context.Background()
+
+	close(stop)
+	s.peakGoroutines = <-peak
+	if createdAfter, ok := goroutinesCreated(); ok && haveCreated {
+		s.goroutinesCreated = createdAfter - createdBefore
+	}
+	var msAfter runtime.MemStats
+	runtime.ReadMemStats(&msAfter)
+	s.gcCycles = msAfter.NumGC - msBefore.NumGC
+	s.gcPauseTotal = time.Duration(msAfter.PauseTotalNs - msBefore.PauseTotalNs)
+	s.gcPauseMax = gcPauseMax(&msBefore, &msAfter)
+	blocksAfter, cyclesPerSecond := readBlockProfile()
+	s.blocks = blockSites(blocksBefore, blocksAfter, cyclesPerSecond)
+	return s
+}
+
+// sampleGoroutines returns the most goroutines seen alive, itself not included, until stop is closed.
+func sampleGoroutines(stop <-chan struct{}) int {
+	peak := 0
+	tick := time.NewTicker(time.Millisecond)
+	defer tick.Stop()
+	for {
+		if n := runtime.NumGoroutine() - 1; n > peak {
+			peak = n
+		}
+		select {
+		case <-stop:
+			return peak
+		case <-tick.C:
+		}
+	}
+}
+
+const goroutinesCreatedMetric = "/sched/goroutines-created:goroutines"
+
+// goroutinesCreated returns how many goroutines the program has started, on runtimes that count them.
+func goroutinesCreated() (int64, bool) {
+	for _, d := range metrics.All() {
+		if d.Name == goroutinesCreatedMetric {
+			sample := []metrics.Sample{{Name: goroutinesCreatedMetric}}
+			metrics.Read(sample)
+			return int64(sample[0].Value.Uint64()), true
+		}
+	}
+	return 0, false
+}
+
+// blockRecord is one stack of the block profile: how often and how long goroutines waited there.
+type blockRecord struct {
+	cycles int64
+	count  int64
+	frames []string // "function file:line", innermost first
+}
+
+var blockProfileMu sync.Mutex
+
+// readBlockProfile parses the text form of the block profile, keyed by stack.
+/* pprof writes it as:
+
+--- contention:
+cycles/second=1999992075
+40323600 1 @ 0x414c72 0x4de825 0x44aaa7
+#	0x414c71	runtime.chanrecv1+0x11	/usr/local/go/src/runtime/chan.go:509
+#	0x4de824	main.main+0x84		/tmp/main.go:9
+*/
+func readBlockProfile() (records map[string]blockRecord, cyclesPerSecond int64) {
+	blockProfileMu.Lock()
+	defer blockProfileMu.Unlock()
+	var buf bytes.Buffer
+	pprof.Lookup("block").WriteTo(&buf, 1)
+
+	records = map[string]blockRecord{}
+	var key string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "cycles/second="):
+			cyclesPerSecond, _ = strconv.ParseInt(strings.TrimPrefix(line, "cycles/second="), 10, 64)
+		case strings.HasPrefix(line, "#"):
+			fields := strings.Fields(strings.TrimPrefix(line, "#"))
+			if key == "" || len(fields) < 3 {
+				continue
+			}
+			r := records[key]
+			fn := fields[1]
+			if p := strings.LastIndex(fn, "+0x"); p >= 0 {
+				fn = fn[:p]
+			}
+			r.frames = append(r.frames, fn+" "+fields[2])
+			records[key] = r
+		case strings.Contains(line, " @ "):
+			head, stack, _ := strings.Cut(line, " @ ")
+			var r blockRecord
+			fmt.Sscan(head, &r.cycles, &r.count)
+			key = stack
+			records[key] = r
+		default:
+			key = ""
+		}
+	}
+	return records, cyclesPerSecond
+}
+
+// blockSites turns the growth of the block profile between two reads into waits per place.
+func blockSites(before, after map[string]blockRecord, cyclesPerSecond int64) []blockSite {
+	sites := map[string]*blockSite{}
+	for key, r := range after {
+		prev := before[key]
+		count, cycles := r.count-prev.count, r.cycles-prev.cycles
+		if count <= 0 || len(r.frames) == 0 {
+			continue
+		}
+		op := blockOp(r.frames[0])
+		if op == "" {
+			continue
+		}
+		// Waits outside this program, such as the trace writer's, and the
+		// summarizer's own waits in this file are left out.
+		site := blockSite{op: op}
+		for _, f := range r.frames {
+			fn, pos, _ := strings.Cut(f, " ")
+			if strings.HasPrefix(fn, ownPackage) {
+				site.function = "main." + strings.TrimPrefix(fn, ownPackage)
+				site.location = filepath.Base(pos)
+				break
+			}
+		}
+		if site.function == "" || site.location == "runtime_trace.go" || strings.HasPrefix(site.location, "runtime_trace.go:") {
+			continue
+		}
+		id := site.op + "|" + site.function + "|" + site.location
+		if sites[id] == nil {
+			sites[id] = &site
+		}
+		sites[id].count += count
+		if cyclesPerSecond > 0 {
+			sites[id].delay += time.Duration(float64(cycles) / float64(cyclesPerSecond) * float64(time.Second))
+		}
+	}
+
+	var out []blockSite
+	for _, s := range sites {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].delay != out[j].delay {
+			return out[i].delay > out[j].delay
+		}
+		return out[i].function+out[i].location < out[j].function+out[j].location
+	})
+	return out
+}
+
+// blockOp names the kind of wait from the innermost frame of a block profile stack.
+func blockOp(frame string) string {
+	fn, _, _ := strings.Cut(frame, " ")
+	switch {
+	case strings.HasPrefix(fn, "runtime.chanrecv"):
+		return "chan receive"
+	case strings.HasPrefix(fn, "runtime.chansend"):
+		return "chan send"
+	case fn == "runtime.selectgo":
+		return "select"
+	case strings.HasPrefix(fn, "sync."):
+		return "sync"
+	}
+	return ""
+}
+
+// print writes the summary as a short report.
+func (s traceSummary) print(w io.Writer) {
+	fmt.Fprintf(w, "trace summary for %s\n", s.name)
+	fmt.Fprintf(w, "  %-22s  %v\n", "wall time", s.wall.Round(time.Millisecond))
+	started := "?"
+	if s.goroutinesCreated >= 0 {
+		started = strconv.FormatInt(s.goroutinesCreated, 10)
+	}
+	fmt.Fprintf(w, "  %-22s  %s started, peak %d\n", "goroutines", started, s.peakGoroutines)
+
+	for _, onChannel := range []bool{true, false} {
+		var total time.Duration
+		var waits int64
+		var sites []blockSite
+		for _, b := range s.blocks {
+			if b.onChannel() == onChannel {
+				total += b.delay
+				waits += b.count
+				sites = append(sites, b)
+			}
+		}
+		title := "blocked on channels"
+		if !onChannel {
+			if waits == 0 {
+				continue
+			}
+			title = "blocked on sync"
+		}
+		fmt.Fprintf(w, "  %-22s  %v in %d waits\n", title, total.Round(time.Microsecond), waits)
+		for _, b := range sites {
+			fmt.Fprintf(w, "    %-12s  %10v  %3dx  %s  %s\n", b.op, b.delay.Round(time.Microsecond), b.count, b.function, b.location)
+		}
+	}
+
+	cycles := "cycles"
+	if s.gcCycles == 1 {
+		cycles = "cycle"
+	}
+	fmt.Fprintf(w, "  %-22s  %d %s, pauses %v total, %v max\n", "GC", s.gcCycles, cycles, s.gcPauseTotal, s.gcPauseMax)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlockSites(t *testing.T) {
+	recv := []string{
+		"runtime.chanrecv1 /usr/local/go/src/runtime/chan.go:509",
+		ownPackage + "channelExample /src/channels.go:44",
+		ownPackage + "main /src/main.go:12",
+	}
+	before := map[string]blockRecord{
+		"0x1 0x2": {cycles: 1000, count: 1, frames: recv},
+	}
+	after := map[string]blockRecord{
+		"0x1 0x2": {cycles: 3000, count: 3, frames: recv},
+		"0x3 0x4": {cycles: 500, count: 1, frames: []string{
+			"sync.(*WaitGroup).Wait /usr/local/go/src/sync/waitgroup.go:118",
+			ownPackage + "sumSquaresWaitGroup /src/sync_examples.go:53",
+		}},
+		"0x5": {cycles: 9000, count: 1, frames: []string{
+			"runtime.selectgo /usr/local/go/src/runtime/select.go:335",
+			ownPackage + "sampleGoroutines /src/runtime_trace.go:130",
+		}},
+		"0x6": {cycles: 9000, count: 1, frames: []string{
+			"runtime.chanrecv1 /usr/local/go/src/runtime/chan.go:509",
+			"runtime/trace.Start.func1 /usr/local/go/src/runtime/trace/trace.go:140",
+		}},
+	}
+
+	got := blockSites(before, after, 1000)
+	want := []blockSite{
+		{op: "chan receive", function: "main.channelExample", location: "channels.go:44", count: 2, delay: 2 * time.Second},
+		{op: "sync", function: "main.sumSquaresWaitGroup", location: "sync_examples.go:53", count: 1, delay: 500 * time.Millisecond},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("blockSites = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("site %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRunTraced(t *testing.T) {
+	var out bytes.Buffer
+	if err := startTrace(&out); err != nil {
+		t.Fatal(err)
+	}
+	ex, _ := lookupExample("channels")
+	ec := newExampleContext(io.Discard)
+	fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+	ec.clock = fc
+	s := runTraced(ex, ec, func() { fc.run(func() { ex.run(ec) }) })
+	stopTrace()
+
+	if out.Len() == 0 {
+		t.Error("no execution trace was written")
+	}
+	if s.peakGoroutines < 1 {
+		t.Errorf("peak goroutines = %d, want at least 1", s.peakGoroutines)
+	}
+	var report strings.Builder
+	s.print(&report)
+	for _, want := range []string{
+		"trace summary for channels",
+		"blocked on channels",
+		"chan receive",
+		"main.channelExample  channels.go:",
+		"GC  ",
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("summary is missing %q:\n%s", want, report.String())
+		}
+	}
+	if strings.Contains(report.String(), "runtime_trace.go") {
+		t.Errorf("summary reports the summarizer's own waits:\n%s", report.String())
+	}
+}
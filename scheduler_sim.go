@@ -0,0 +1,876 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// A discrete-event simulator of the Go scheduler described in goroutine.go.
+/* The simulator models the three structures the runtime uses:
+
+G - a goroutine: the steps it still has to run and how long it waited to run.
+M - an OS thread. An M needs a P to run Go code.
+P - a processor. There are exactly GOMAXPROCS Ps. Each P has a local run queue
+    (at most 256 Gs) and a runnext slot that holds the goroutine it just created.
+
+Besides the local queues there is one global run queue, protected by the global sched lock.
+A P looking for work (findRunnable) checks, in order:
+1. the global queue, once every 61 schedule ticks, so it is never starved;
+2. its runnext slot, then its local run queue;
+3. the global queue, taking a batch of goroutines into its local queue;
+4. the other Ps' local queues, stealing half of the first non-empty one it finds.
+If it finds nothing the P goes idle, and its M is parked.
+
+A G that runs for a whole time slice (10ms) while other work is waiting is preempted
+and put on the global queue.
+
+A blocking syscall keeps its M. If the syscall is still running after the sysmon
+delay (20µs), the P is handed off to another M so other goroutines keep running.
+When the syscall returns, the M tries to get its old P back, then any idle P;
+if there is none, the G goes to the global queue and the M goes idle.
+
+Workloads are JSON. Top-level goroutines arrive at their start time; templates are
+started by a "spawn" step, like a go statement:
+
+{
+  "name": "fan-out",
+  "goroutines": [
+    {"name": "main", "steps": [{"spawn": "worker", "count": 4}, {"cpu": "1ms"}]}
+  ],
+  "templates": {
+    "worker": {"steps": [{"cpu": "5ms"}, {"syscall": "2ms"}, {"block": "1ms"}, {"cpu": "5ms"}]}
+  }
+}
+
+A "block" step parks the goroutine without holding an M, like a channel receive or time.Sleep.
+
+	go-concepts schedsim -preset routine-example -gomaxprocs 2
+	go-concepts schedsim -workload fan-out.json -gomaxprocs 4 -v
+*/
+type schedWorkload struct {
+	Name       string                `json:"name"`
+	Goroutines []schedGSpec          `json:"goroutines"`
+	Templates  map[string]schedGSpec `json:"templates"`
+}
+
+type schedGSpec struct {
+	Name  string        `json:"name"`
+	Start schedDuration `json:"start"`
+	Count int           `json:"count"`
+	Steps []schedStep   `json:"steps"`
+}
+
+// A schedStep sets exactly one of CPU, Syscall, Block or Spawn.
+type schedStep struct {
+	CPU     schedDuration `json:"cpu"`
+	Syscall schedDuration `json:"syscall"`
+	Block   schedDuration `json:"block"`
+	Spawn   string        `json:"spawn"`
+	Count   int           `json:"count"` // number of goroutines to spawn, default 1
+}
+
+// schedDuration is a time.Duration written as "5ms" in JSON.
+type schedDuration time.Duration
+
+func (d *schedDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5ms\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = schedDuration(v)
+	return nil
+}
+
+var schedPresets = map[string]schedWorkload{
+	// goRoutineExample: main prints three lines, starts two goroutines and sleeps 2s.
+	// A Println is a little CPU and a write syscall.
+	"routine-example": {
+		Name: "routine-example",
+		Goroutines: []schedGSpec{{Name: "main", Steps: []schedStep{
+			{CPU: us(3)}, {Syscall: us(5)}, {CPU: us(3)}, {Syscall: us(5)}, {CPU: us(3)}, {Syscall: us(5)},
+			{Spawn: "routine"}, {Spawn: "anonymous"},
+			{Block: schedDuration(2 * time.Second)},
+			{CPU: us(3)}, {Syscall: us(5)},
+		}}},
+		Templates: map[string]schedGSpec{
+			"routine": {Steps: []schedStep{
+				{CPU: us(3)}, {Syscall: us(5)}, {CPU: us(3)}, {Syscall: us(5)}, {CPU: us(3)}, {Syscall: us(5)},
+			}},
+			"anonymous": {Steps: []schedStep{{CPU: us(3)}, {Syscall: us(5)}}},
+		},
+	},
+	// Sixteen CPU-bound goroutines that each need 30ms: time slices and GOMAXPROCS matter.
+	"cpu-bound": {
+		Name: "cpu-bound",
+		Goroutines: []schedGSpec{{Name: "main", Steps: []schedStep{
+			{Spawn: "worker", Count: 16}, {Block: schedDuration(time.Millisecond)},
+		}}},
+		Templates: map[string]schedGSpec{
+			"worker": {Steps: []schedStep{{CPU: schedDuration(30 * time.Millisecond)}}},
+		},
+	},
+	// Goroutines that spend most of their time in blocking syscalls: Ps are handed off.
+	"syscall-heavy": {
+		Name: "syscall-heavy",
+		Goroutines: []schedGSpec{{Name: "io", Count: 8, Steps: []schedStep{
+			{CPU: schedDuration(time.Millisecond)}, {Syscall: schedDuration(5 * time.Millisecond)},
+			{CPU: schedDuration(time.Millisecond)}, {Syscall: schedDuration(5 * time.Millisecond)},
+			{CPU: schedDuration(time.Millisecond)},
+		}}},
+	},
+}
+
+func us(n int) schedDuration { return schedDuration(time.Duration(n) * time.Microsecond) }
+
+// schedConfig holds the tunables of the simulated runtime.
+type schedConfig struct {
+	gomaxprocs   int
+	timeSlice    time.Duration // forcePreemptNS
+	sysmonDelay  time.Duration // how long a syscall runs before its P is retaken
+	localRunqCap int
+	seed         int64
+}
+
+func defaultSchedConfig(gomaxprocs int) schedConfig {
+	return schedConfig{
+		gomaxprocs:   gomaxprocs,
+		timeSlice:    10 * time.Millisecond,
+		sysmonDelay:  20 * time.Microsecond,
+		localRunqCap: 256,
+		seed:         1,
+	}
+}
+
+type gStatus int
+
+const (
+	gRunnable gStatus = iota
+	gRunning
+	gSyscall
+	gWaiting
+	gDead
+)
+
+type simG struct {
+	id        int
+	name      string
+	steps     []schedStep
+	pc        int
+	remaining time.Duration // CPU left in the current step
+	status    gStatus
+	lastP     *simP
+
+	created       time.Duration
+	runnableSince time.Duration
+	waited        time.Duration
+	finished      time.Duration
+}
+
+type pStatus int
+
+const (
+	pIdle pStatus = iota
+	pRunning
+	pSyscall
+)
+
+type simP struct {
+	id        int
+	status    pStatus
+	m         *simM
+	runq      []*simG
+	runnext   *simG
+	schedtick int
+
+	busy        time.Duration
+	syscall     time.Duration
+	syscallFrom time.Duration
+	ran         int
+	steals      int
+	handoffs    int
+}
+
+type simM struct {
+	id   int
+	p    *simP
+	oldp *simP // the P held before a syscall, tried first on return
+}
+
+type schedEventKind int
+
+const (
+	evArrive schedEventKind = iota
+	evCPUDone
+	evSyscallDone
+	evRetake
+	evUnblock
+	evSchedule // p looks for its next goroutine
+)
+
+type schedEvent struct {
+	at   time.Duration
+	seq  int
+	kind schedEventKind
+	g    *simG
+	p    *simP
+	m    *simM
+	d    time.Duration
+}
+
+type schedEvents []*schedEvent
+
+func (q schedEvents) Len() int { return len(q) }
+func (q schedEvents) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	// A P rescheduling goes first, as if schedule had been called directly.
+	if (q[i].kind == evSchedule) != (q[j].kind == evSchedule) {
+		return q[i].kind == evSchedule
+	}
+	return q[i].seq < q[j].seq
+}
+func (q schedEvents) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *schedEvents) Push(x interface{}) { *q = append(*q, x.(*schedEvent)) }
+func (q *schedEvents) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+type schedSim struct {
+	cfg       schedConfig
+	workload  schedWorkload
+	now       time.Duration
+	ps        []*simP
+	ms        []*simM
+	idleMs    []*simM
+	globrunq  []*simG
+	gs        []*simG
+	events    schedEvents
+	seq       int
+	rng       *rand.Rand
+	trace     io.Writer
+	maxGlobal int
+}
+
+func newSchedSim(w schedWorkload, cfg schedConfig) *schedSim {
+	s := &schedSim{cfg: cfg, workload: w, rng: rand.New(rand.NewSource(cfg.seed))}
+	for i := 0; i < cfg.gomaxprocs; i++ {
+		s.ps = append(s.ps, &simP{id: i})
+	}
+	for _, spec := range w.Goroutines {
+		for i := 0; i < max1(spec.Count); i++ {
+			g := s.newG(spec, spec.Name, i, max1(spec.Count))
+			s.at(time.Duration(spec.Start), &schedEvent{kind: evArrive, g: g})
+		}
+	}
+	return s
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func (s *schedSim) newG(spec schedGSpec, name string, i, count int) *simG {
+	if count > 1 {
+		name = fmt.Sprintf("%s#%d", name, i+1)
+	}
+	g := &simG{id: len(s.gs) + 1, name: name, steps: spec.Steps, created: s.now, status: gWaiting}
+	s.gs = append(s.gs, g)
+	return g
+}
+
+func (s *schedSim) at(t time.Duration, e *schedEvent) {
+	s.seq++
+	e.at, e.seq = t, s.seq
+	heap.Push(&s.events, e)
+}
+
+func (s *schedSim) tracef(format string, args ...interface{}) {
+	if s.trace != nil {
+		fmt.Fprintf(s.trace, "%12v  %s\n", s.now, fmt.Sprintf(format, args...))
+	}
+}
+
+// run processes events until every goroutine has finished.
+func (s *schedSim) run() error {
+	for s.events.Len() > 0 {
+		e := heap.Pop(&s.events).(*schedEvent)
+		s.now = e.at
+		switch e.kind {
+		case evArrive:
+			e.g.created = s.now
+			s.tracef("G%d %s created", e.g.id, e.g.name)
+			s.ready(e.g, nil)
+		case evCPUDone:
+			s.cpuDone(e.p, e.g, e.d)
+		case evSyscallDone:
+			s.syscallDone(e.m, e.g, e.d)
+		case evRetake:
+			s.retake(e.p, e.m, e.g)
+		case evUnblock:
+			s.tracef("G%d %s unblocked", e.g.id, e.g.name)
+			s.ready(e.g, e.g.lastP)
+		case evSchedule:
+			s.schedule(e.p)
+		}
+	}
+	for _, g := range s.gs {
+		if g.status != gDead {
+			return fmt.Errorf("G%d %s never finished", g.id, g.name)
+		}
+	}
+	return nil
+}
+
+// ready makes g runnable on p's local queue, or on the global queue if p is nil,
+// and wakes an idle P if there is one.
+func (s *schedSim) ready(g *simG, p *simP) {
+	g.status = gRunnable
+	g.runnableSince = s.now
+	if p == nil {
+		s.globrunq = append(s.globrunq, g)
+		if len(s.globrunq) > s.maxGlobal {
+			s.maxGlobal = len(s.globrunq)
+		}
+	} else {
+		s.runqput(p, g)
+	}
+	s.wakeP()
+}
+
+// runqput adds g to p's local queue. A full queue moves half of itself and g
+// to the global queue, like runqputslow.
+func (s *schedSim) runqput(p *simP, g *simG) {
+	if len(p.runq) < s.cfg.localRunqCap {
+		p.runq = append(p.runq, g)
+		return
+	}
+	n := len(p.runq) / 2
+	s.globrunq = append(s.globrunq, p.runq[:n]...)
+	s.globrunq = append(s.globrunq, g)
+	p.runq = append([]*simG(nil), p.runq[n:]...)
+	if len(s.globrunq) > s.maxGlobal {
+		s.maxGlobal = len(s.globrunq)
+	}
+}
+
+// wakeP starts an M on an idle P when there is work it could pick up.
+func (s *schedSim) wakeP() {
+	if !s.stealableWork() {
+		return
+	}
+	for _, p := range s.ps {
+		if p.status == pIdle && p.m == nil {
+			m := s.getM()
+			s.acquireP(m, p)
+			s.tracef("P%d woken on M%d", p.id, m.id)
+			s.reschedule(p)
+			return
+		}
+	}
+}
+
+func (s *schedSim) stealableWork() bool {
+	if len(s.globrunq) > 0 {
+		return true
+	}
+	for _, p := range s.ps {
+		if len(p.runq) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getM returns an idle M, or starts a new OS thread.
+func (s *schedSim) getM() *simM {
+	if n := len(s.idleMs); n > 0 {
+		m := s.idleMs[n-1]
+		s.idleMs = s.idleMs[:n-1]
+		return m
+	}
+	m := &simM{id: len(s.ms)}
+	s.ms = append(s.ms, m)
+	return m
+}
+
+func (s *schedSim) acquireP(m *simM, p *simP) {
+	m.p, p.m = p, m
+	p.status = pRunning
+}
+
+// releaseP parks p's M and leaves p idle.
+func (s *schedSim) releaseP(p *simP) {
+	m := p.m
+	p.m, p.status = nil, pIdle
+	if m != nil {
+		m.p = nil
+		s.idleMs = append(s.idleMs, m)
+	}
+}
+
+// reschedule has p look for its next goroutine as soon as the current event is
+// handled. Going through the event queue, rather than calling schedule, keeps the
+// call stack flat however many goroutines a P runs at the same instant.
+func (s *schedSim) reschedule(p *simP) {
+	s.at(s.now, &schedEvent{kind: evSchedule, p: p})
+}
+
+// schedule finds the next goroutine for p and runs it, or idles p.
+func (s *schedSim) schedule(p *simP) {
+	g := s.findRunnable(p)
+	if g == nil {
+		s.tracef("P%d idle", p.id)
+		s.releaseP(p)
+		return
+	}
+	g.waited += s.now - g.runnableSince
+	g.status = gRunning
+	g.lastP = p
+	p.ran++
+	s.tracef("P%d M%d runs G%d %s", p.id, p.m.id, g.id, g.name)
+	// Like a spinning M that found work, wake another P if work is left over.
+	s.wakeP()
+	s.runStep(p, g)
+}
+
+func (s *schedSim) findRunnable(p *simP) *simG {
+	p.schedtick++
+	if p.schedtick%61 == 0 && len(s.globrunq) > 0 {
+		g := s.globrunq[0]
+		s.globrunq = s.globrunq[1:]
+		return g
+	}
+	if g := p.runnext; g != nil {
+		p.runnext = nil
+		return g
+	}
+	if len(p.runq) > 0 {
+		g := p.runq[0]
+		p.runq = p.runq[1:]
+		return g
+	}
+	if g := s.globrunqget(p); g != nil {
+		return g
+	}
+	return s.steal(p)
+}
+
+// globrunqget takes a fair share of the global queue into p's local queue.
+func (s *schedSim) globrunqget(p *simP) *simG {
+	if len(s.globrunq) == 0 {
+		return nil
+	}
+	n := len(s.globrunq)/s.cfg.gomaxprocs + 1
+	if n > len(s.globrunq) {
+		n = len(s.globrunq)
+	}
+	if max := s.cfg.localRunqCap / 2; n > max {
+		n = max
+	}
+	batch := s.globrunq[:n]
+	s.globrunq = s.globrunq[n:]
+	p.runq = append(p.runq, batch[1:]...)
+	return batch[0]
+}
+
+// steal takes half of another P's local queue, visiting the Ps in random order.
+func (s *schedSim) steal(p *simP) *simG {
+	for _, i := range s.rng.Perm(len(s.ps)) {
+		victim := s.ps[i]
+		if victim == p || len(victim.runq) == 0 {
+			continue
+		}
+		n := (len(victim.runq) + 1) / 2
+		stolen := victim.runq[:n]
+		victim.runq = append([]*simG(nil), victim.runq[n:]...)
+		p.runq = append(p.runq, stolen[:n-1]...)
+		p.steals++
+		s.tracef("P%d stole %d from P%d", p.id, n, victim.id)
+		return stolen[n-1]
+	}
+	return nil
+}
+
+// runStep runs g's current step on p.
+func (s *schedSim) runStep(p *simP, g *simG) {
+	for {
+		if g.pc >= len(g.steps) {
+			g.status = gDead
+			g.finished = s.now
+			s.tracef("G%d %s exits", g.id, g.name)
+			s.reschedule(p)
+			return
+		}
+		step := g.steps[g.pc]
+		switch {
+		case step.Spawn != "":
+			spec := s.workload.Templates[step.Spawn]
+			for i := 0; i < max1(step.Count); i++ {
+				child := s.newG(spec, step.Spawn, i, max1(step.Count))
+				child.runnableSince = s.now
+				child.status = gRunnable
+				s.tracef("G%d %s: go %s (G%d)", g.id, g.name, child.name, child.id)
+				// A new goroutine goes to runnext; the one it displaces goes to the local queue.
+				if p.runnext != nil {
+					s.runqput(p, p.runnext)
+				}
+				p.runnext = child
+			}
+			g.pc++
+			s.wakeP()
+			continue
+
+		case step.CPU > 0:
+			if g.remaining == 0 {
+				g.remaining = time.Duration(step.CPU)
+			}
+			slice := g.remaining
+			if slice > s.cfg.timeSlice {
+				slice = s.cfg.timeSlice
+			}
+			s.at(s.now+slice, &schedEvent{kind: evCPUDone, p: p, g: g, d: slice})
+			return
+
+		case step.Syscall > 0:
+			g.status = gSyscall
+			p.status = pSyscall
+			p.syscallFrom = s.now
+			m := p.m
+			d := time.Duration(step.Syscall)
+			s.tracef("G%d %s enters syscall on M%d", g.id, g.name, m.id)
+			s.at(s.now+d, &schedEvent{kind: evSyscallDone, m: m, g: g, d: d})
+			if d > s.cfg.sysmonDelay {
+				s.at(s.now+s.cfg.sysmonDelay, &schedEvent{kind: evRetake, p: p, m: m, g: g})
+			}
+			return
+
+		case step.Block > 0:
+			g.status = gWaiting
+			g.pc++
+			s.tracef("G%d %s blocks for %v", g.id, g.name, time.Duration(step.Block))
+			s.at(s.now+time.Duration(step.Block), &schedEvent{kind: evUnblock, g: g})
+			s.reschedule(p)
+			return
+
+		default:
+			g.pc++
+		}
+	}
+}
+
+func (s *schedSim) cpuDone(p *simP, g *simG, slice time.Duration) {
+	p.busy += slice
+	g.remaining -= slice
+	if g.remaining > 0 && (s.stealableWork() || p.runnext != nil) {
+		// Used up its time slice while others wait: preempt to the global queue.
+		s.tracef("G%d %s preempted on P%d", g.id, g.name, p.id)
+		g.status = gRunnable
+		g.runnableSince = s.now
+		s.globrunq = append(s.globrunq, g)
+		s.reschedule(p)
+		return
+	}
+	if g.remaining == 0 {
+		g.pc++
+	}
+	s.runStep(p, g)
+}
+
+// retake is sysmon finding a P stuck in a syscall and handing it to another M.
+func (s *schedSim) retake(p *simP, m *simM, g *simG) {
+	if g.status != gSyscall || p.status != pSyscall || p.m != m {
+		return
+	}
+	p.syscall += s.now - p.syscallFrom
+	p.handoffs++
+	m.p, m.oldp = nil, p
+	p.m, p.status = nil, pIdle
+	s.tracef("sysmon retakes P%d from M%d in syscall", p.id, m.id)
+	if s.stealableWork() || p.runnext != nil {
+		nm := s.getM()
+		s.acquireP(nm, p)
+		s.tracef("P%d handed off to M%d", p.id, nm.id)
+		s.reschedule(p)
+	}
+}
+
+func (s *schedSim) syscallDone(m *simM, g *simG, d time.Duration) {
+	g.pc++
+	if p := m.p; p != nil {
+		// Fast path: the P was never taken away.
+		p.syscall += s.now - p.syscallFrom
+		p.status = pRunning
+		g.status = gRunning
+		s.runStep(p, g)
+		return
+	}
+
+	s.tracef("G%d %s returns from syscall on M%d without a P", g.id, g.name, m.id)
+	p := m.oldp
+	m.oldp = nil
+	if p == nil || p.status != pIdle || p.m != nil {
+		p = nil
+		for _, idle := range s.ps {
+			if idle.status == pIdle && idle.m == nil {
+				p = idle
+				break
+			}
+		}
+	}
+	if p != nil {
+		s.acquireP(m, p)
+		g.status = gRunning
+		g.lastP = p
+		s.tracef("M%d reacquires P%d", m.id, p.id)
+		s.runStep(p, g)
+		return
+	}
+	s.idleMs = append(s.idleMs, m)
+	s.ready(g, nil)
+}
+
+// schedReport is the outcome of a simulation.
+type schedReport struct {
+	workload   string
+	gomaxprocs int
+	makespan   time.Duration
+	ms         int
+	maxGlobal  int
+	ps         []*simP
+	gs         []*simG
+}
+
+func (s *schedSim) report() schedReport {
+	r := schedReport{workload: s.workload.Name, gomaxprocs: s.cfg.gomaxprocs, ms: len(s.ms),
+		maxGlobal: s.maxGlobal, ps: s.ps, gs: s.gs}
+	for _, g := range s.gs {
+		if g.finished > r.makespan {
+			r.makespan = g.finished
+		}
+	}
+	return r
+}
+
+func (r schedReport) print(w io.Writer, verbose bool) {
+	fmt.Fprintf(w, "workload %s, GOMAXPROCS=%d: %d goroutines on %d Ms, finished at %v, global run queue peaked at %d\n",
+		r.workload, r.gomaxprocs, len(r.gs), r.ms, r.makespan, r.maxGlobal)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "P\tbusy\tsyscall\tidle\tutilization\tGs run\tsteals\thandoffs\t")
+	for _, p := range r.ps {
+		idle := r.makespan - p.busy - p.syscall
+		util := 0.0
+		if r.makespan > 0 {
+			util = 100 * float64(p.busy) / float64(r.makespan)
+		}
+		fmt.Fprintf(tw, "P%d\t%v\t%v\t%v\t%.1f%%\t%d\t%d\t%d\t\n", p.id, p.busy, p.syscall, idle, util, p.ran, p.steals, p.handoffs)
+	}
+	tw.Flush()
+
+	waits := make([]time.Duration, len(r.gs))
+	var total time.Duration
+	for i, g := range r.gs {
+		waits[i] = g.waited
+		total += g.waited
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	if len(waits) > 0 {
+		pct := func(q float64) time.Duration { return waits[int(q*float64(len(waits)-1))] }
+		fmt.Fprintf(w, "scheduling latency (runnable to running): mean %v, p50 %v, p95 %v, max %v\n",
+			total/time.Duration(len(waits)), pct(0.5), pct(0.95), waits[len(waits)-1])
+	}
+
+	if verbose {
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "G\tname\tcreated\tfinished\twaited to run")
+		for _, g := range r.gs {
+			fmt.Fprintf(tw, "G%d\t%s\t%v\t%v\t%v\n", g.id, g.name, g.created, g.finished, g.waited)
+		}
+		tw.Flush()
+	}
+}
+
+// simulateSchedule runs a workload and returns its report.
+func simulateSchedule(w schedWorkload, cfg schedConfig, trace io.Writer) (schedReport, error) {
+	if cfg.gomaxprocs < 1 {
+		return schedReport{}, errors.New("GOMAXPROCS must be at least 1")
+	}
+	if cfg.gomaxprocs > maxSchedProcs {
+		return schedReport{}, fmt.Errorf("GOMAXPROCS must be at most %d", maxSchedProcs)
+	}
+	if cfg.timeSlice <= 0 {
+		return schedReport{}, errors.New("the time slice must be positive")
+	}
+	for _, spec := range w.Goroutines {
+		if spec.Start < 0 {
+			return schedReport{}, fmt.Errorf("%s starts at %v, before the program does", spec.Name, time.Duration(spec.Start))
+		}
+		if err := checkSchedSteps(w, spec); err != nil {
+			return schedReport{}, err
+		}
+	}
+	for _, spec := range w.Templates {
+		if err := checkSchedSteps(w, spec); err != nil {
+			return schedReport{}, err
+		}
+	}
+	total := 0
+	for _, spec := range w.Goroutines {
+		n, err := schedGCount(w, spec, map[string]bool{})
+		if err != nil {
+			return schedReport{}, err
+		}
+		if total += max1(spec.Count) * n; total > maxSchedGoroutines {
+			return schedReport{}, fmt.Errorf("the workload starts more than %d goroutines", maxSchedGoroutines)
+		}
+	}
+	s := newSchedSim(w, cfg)
+	s.trace = trace
+	if err := s.run(); err != nil {
+		return schedReport{}, err
+	}
+	return s.report(), nil
+}
+
+// maxSchedProcs bounds GOMAXPROCS: the simulator creates every P up front.
+const maxSchedProcs = 1024
+
+// maxSchedGoroutines bounds the goroutines a workload may start, spawned ones included.
+const maxSchedGoroutines = 100_000
+
+// schedGCount returns how many goroutines one instance of spec starts, itself
+// included. A template that spawns itself, directly or through others, would never
+// finish and is an error; visiting holds the templates being counted.
+func schedGCount(w schedWorkload, spec schedGSpec, visiting map[string]bool) (int, error) {
+	n := 1
+	for _, step := range spec.Steps {
+		if step.Spawn == "" {
+			continue
+		}
+		if visiting[step.Spawn] {
+			return 0, fmt.Errorf("template %q spawns itself", step.Spawn)
+		}
+		visiting[step.Spawn] = true
+		m, err := schedGCount(w, w.Templates[step.Spawn], visiting)
+		delete(visiting, step.Spawn)
+		if err != nil {
+			return 0, err
+		}
+		if n += max1(step.Count) * m; n > maxSchedGoroutines {
+			return 0, fmt.Errorf("the workload starts more than %d goroutines", maxSchedGoroutines)
+		}
+	}
+	return n, nil
+}
+
+func checkSchedSteps(w schedWorkload, spec schedGSpec) error {
+	for i, step := range spec.Steps {
+		set := 0
+		for _, ok := range []bool{step.CPU > 0, step.Syscall > 0, step.Block > 0, step.Spawn != ""} {
+			if ok {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("%s step %d: set exactly one of cpu, syscall, block or spawn", spec.Name, i+1)
+		}
+		if step.Spawn != "" {
+			if _, ok := w.Templates[step.Spawn]; !ok {
+				return fmt.Errorf("%s step %d: spawn of unknown template %q", spec.Name, i+1, step.Spawn)
+			}
+		}
+	}
+	return nil
+}
+
+// schedulerExample compares goRoutineExample's shape and a CPU-bound fan-out on one and four Ps.
+func schedulerExample(ec *exampleContext) {
+	ec.println("Scheduler simulator")
+	for _, name := range []string{"routine-example", "cpu-bound"} {
+		for _, procs := range []int{1, 4} {
+			r, err := simulateSchedule(schedPresets[name], defaultSchedConfig(procs), nil)
+			if err != nil {
+				ec.println(err)
+				return
+			}
+			r.print(ec.out, false)
+			ec.println()
+		}
+	}
+}
+
+func schedsimCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("schedsim", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var presets []string
+	for name := range schedPresets {
+		presets = append(presets, name)
+	}
+	sort.Strings(presets)
+	preset := fs.String("preset", "routine-example", "built-in workload: "+strings.Join(presets, ", "))
+	workloadFile := fs.String("workload", "", "JSON workload description (overrides -preset)")
+	procs := fs.Int("gomaxprocs", 4, "number of Ps")
+	seed := fs.Int64("seed", 1, "seed for the work-stealing order")
+	slice := fs.Duration("timeslice", 10*time.Millisecond, "time slice before preemption")
+	verbose := fs.Bool("v", false, "report every goroutine")
+	trace := fs.Bool("trace", false, "log every scheduling decision")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	w, ok := schedPresets[*preset]
+	if *workloadFile != "" {
+		b, err := os.ReadFile(*workloadFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "schedsim:", err)
+			return 1
+		}
+		w = schedWorkload{}
+		if err := json.Unmarshal(b, &w); err != nil {
+			fmt.Fprintf(stderr, "schedsim: %s: %v\n", *workloadFile, err)
+			return 1
+		}
+		if w.Name == "" {
+			w.Name = *workloadFile
+		}
+	} else if !ok {
+		fmt.Fprintf(stderr, "schedsim: unknown preset %q\n", *preset)
+		return 2
+	}
+
+	cfg := defaultSchedConfig(*procs)
+	cfg.seed = *seed
+	cfg.timeSlice = *slice
+	var traceOut io.Writer
+	if *trace {
+		traceOut = stdout
+	}
+	r, err := simulateSchedule(w, cfg, traceOut)
+	if err != nil {
+		fmt.Fprintln(stderr, "schedsim:", err)
+		return 1
+	}
+	r.print(stdout, *verbose)
+	return 0
+}
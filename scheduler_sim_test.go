@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cpuDemand is the total CPU time a workload asks for.
+func cpuDemand(r schedReport) time.Duration {
+	var total time.Duration
+	for _, g := range r.gs {
+		for _, step := range g.steps {
+			total += time.Duration(step.CPU)
+		}
+	}
+	return total
+}
+
+func TestSchedSimAccountsForAllCPU(t *testing.T) {
+	for name, w := range schedPresets {
+		for _, procs := range []int{1, 2, 4, 8} {
+			r, err := simulateSchedule(w, defaultSchedConfig(procs), nil)
+			if err != nil {
+				t.Fatalf("%s on %d Ps: %v", name, procs, err)
+			}
+			var busy time.Duration
+			for _, p := range r.ps {
+				busy += p.busy
+				if p.busy+p.syscall > r.makespan {
+					t.Errorf("%s on %d Ps: P%d busy %v + syscall %v exceeds makespan %v", name, procs, p.id, p.busy, p.syscall, r.makespan)
+				}
+			}
+			if want := cpuDemand(r); busy != want {
+				t.Errorf("%s on %d Ps: Ps were busy %v, workload needs %v", name, procs, busy, want)
+			}
+		}
+	}
+}
+
+func TestSchedSimCPUBoundScalesWithGOMAXPROCS(t *testing.T) {
+	w := schedPresets["cpu-bound"]
+	one, _ := simulateSchedule(w, defaultSchedConfig(1), nil)
+	four, _ := simulateSchedule(w, defaultSchedConfig(4), nil)
+
+	if one.makespan != cpuDemand(one) {
+		t.Errorf("one P finished at %v, want exactly the CPU demand %v", one.makespan, cpuDemand(one))
+	}
+	if four.makespan*3 > one.makespan {
+		t.Errorf("four Ps finished at %v, not about 4x faster than one P (%v)", four.makespan, one.makespan)
+	}
+	for _, p := range four.ps {
+		if p.ran == 0 {
+			t.Errorf("P%d never ran a goroutine", p.id)
+		}
+	}
+}
+
+func TestSchedSimHandsOffPsInLongSyscalls(t *testing.T) {
+	r, err := simulateSchedule(schedPresets["syscall-heavy"], defaultSchedConfig(2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handoffs := 0
+	for _, p := range r.ps {
+		handoffs += p.handoffs
+	}
+	if handoffs == 0 {
+		t.Error("no P was handed off during 5ms syscalls")
+	}
+	if r.ms <= len(r.ps) {
+		t.Errorf("%d Ms for %d Ps: blocked syscalls should need extra threads", r.ms, len(r.ps))
+	}
+}
+
+func TestSchedSimWorkloadJSON(t *testing.T) {
+	const doc = `{
+		"name": "fan-out",
+		"goroutines": [{"name": "main", "steps": [{"spawn": "worker", "count": 4}, {"cpu": "1ms"}]}],
+		"templates": {"worker": {"steps": [{"cpu": "5ms"}, {"syscall": "2ms"}, {"block": "1ms"}, {"cpu": "5ms"}]}}
+	}`
+	var w schedWorkload
+	if err := json.Unmarshal([]byte(doc), &w); err != nil {
+		t.Fatal(err)
+	}
+	r, err := simulateSchedule(w, defaultSchedConfig(2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.gs) != 5 {
+		t.Errorf("got %d goroutines, want main and 4 workers", len(r.gs))
+	}
+	if want := 41 * time.Millisecond; cpuDemand(r) != want {
+		t.Errorf("CPU demand %v, want %v", cpuDemand(r), want)
+	}
+
+	bad := schedWorkload{Goroutines: []schedGSpec{{Name: "main", Steps: []schedStep{{Spawn: "missing"}}}}}
+	if _, err := simulateSchedule(bad, defaultSchedConfig(1), nil); err == nil {
+		t.Error("spawn of an unknown template was accepted")
+	}
+}
+
+func TestSchedSimRejectsUnboundedWorkloads(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"self spawn", `{"goroutines": [{"steps": [{"spawn": "w"}]}], "templates": {"w": {"steps": [{"spawn": "w"}]}}}`, `template "w" spawns itself`},
+		{"spawn cycle", `{"goroutines": [{"steps": [{"spawn": "a"}]}], "templates": {"a": {"steps": [{"spawn": "b"}]}, "b": {"steps": [{"cpu": "1ms"}, {"spawn": "a"}]}}}`, "spawns itself"},
+		{"too many", `{"goroutines": [{"steps": [{"spawn": "a", "count": 1000}]}], "templates": {"a": {"steps": [{"spawn": "b", "count": 1000}]}, "b": {"steps": [{"cpu": "1ms"}]}}}`, "more than 100000 goroutines"},
+	} {
+		var w schedWorkload
+		if err := json.Unmarshal([]byte(tt.doc), &w); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := simulateSchedule(w, defaultSchedConfig(2), nil); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	cfg := defaultSchedConfig(1)
+	cfg.timeSlice = 0
+	if _, err := simulateSchedule(schedPresets["cpu-bound"], cfg, nil); err == nil {
+		t.Error("a zero time slice was accepted")
+	}
+	if _, err := simulateSchedule(schedPresets["cpu-bound"], defaultSchedConfig(maxSchedProcs+1), nil); err == nil {
+		t.Errorf("GOMAXPROCS %d was accepted", maxSchedProcs+1)
+	}
+	early := schedWorkload{Goroutines: []schedGSpec{{Name: "early", Start: schedDuration(-time.Millisecond), Steps: []schedStep{{CPU: schedDuration(time.Millisecond)}}}}}
+	if _, err := simulateSchedule(early, defaultSchedConfig(1), nil); err == nil {
+		t.Error("a negative start time was accepted")
+	}
+}
+
+// TestSchedSimManyShortGoroutines runs goroutines that block without using the CPU,
+// which a P goes through one after another at the same instant.
+func TestSchedSimManyShortGoroutines(t *testing.T) {
+	w := schedWorkload{
+		Goroutines: []schedGSpec{{Name: "main", Steps: []schedStep{{Spawn: "w", Count: 20_000}}}},
+		Templates:  map[string]schedGSpec{"w": {Steps: []schedStep{{Block: schedDuration(time.Millisecond)}}}},
+	}
+	r, err := simulateSchedule(w, defaultSchedConfig(1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.gs) != 20_001 {
+		t.Errorf("got %d goroutines, want 20001", len(r.gs))
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A context.Context carries a deadline and a cancellation signal across goroutines.
+/* ctx.Done() returns a channel that is closed when the work should stop, so it is just
+another case in a select:
+
+	select {
+	case v := <-results:
+		use(v)
+	case <-ctx.Done():
+		return ctx.Err() // context.Canceled or context.DeadlineExceeded
+	}
+
+Contexts form a tree. Cancelling a context cancels every context derived from it, and a
+child's deadline can only be earlier than its parent's. Each variant below starts
+goroutines that select on ctx.Done() and prints when they exit, so nothing is left
+running once the context is done.
+
+The examples use withClockDeadline and withClockTimeout (clock_context.go) rather than
+context.WithDeadline and context.WithTimeout, so they also run on the fake clock.
+*/
+func selectContextExample(ec *exampleContext) {
+	ec.step("deadline", func() { selectDeadline(ec) })
+	ec.step("timeout", func() { selectTimeout(ec) })
+	ec.step("parent-cancel", func() { selectParentCancel(ec) })
+	ec.step("context-err", func() { selectContextErr(ec) })
+}
+
+// tickUntilDone sends an increasing count every interval until ctx is done, then reports on exited.
+func tickUntilDone(ctx context.Context, ec *exampleContext, interval time.Duration, out chan<- int, exited chan<- string) {
+	t := ec.clock.NewTicker(interval)
+	defer t.Stop()
+	for n := 1; ; n++ {
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			exited <- "ticker stopped: " + ctx.Err().Error()
+			return
+		}
+		select {
+		case out <- n:
+		case <-ctx.Done():
+			exited <- "ticker stopped: " + ctx.Err().Error()
+			return
+		}
+	}
+}
+
+// selectDeadline reads ticks until a deadline 1.2s away passes.
+func selectDeadline(ec *exampleContext) {
+	ec.println("Select with a deadline")
+	deadline := ec.clock.Now().Add(1200 * time.Millisecond)
+	ctx, cancel := withClockDeadline(context.Background(), ec.clock, deadline)
+	defer cancel()
+
+	ticks := make(chan int)
+	exited := make(chan string)
+	go tickUntilDone(ctx, ec, 500*time.Millisecond, ticks, exited)
+	for done := false; !done; {
+		select {
+		case n := <-ticks:
+			ec.println("tick", n)
+		case <-ctx.Done():
+			ec.println("deadline reached:", ctx.Err())
+			done = true
+		}
+	}
+	ec.println(<-exited)
+}
+
+// slowLookup returns its answer after d, or gives up when ctx is done.
+func slowLookup(ctx context.Context, ec *exampleContext, d time.Duration, result chan<- string, exited chan<- string) {
+	select {
+	case <-ec.clock.After(d):
+	case <-ctx.Done():
+		exited <- "lookup stopped: " + ctx.Err().Error()
+		return
+	}
+	select {
+	case result <- "answer":
+		exited <- "lookup finished"
+	case <-ctx.Done():
+		exited <- "lookup stopped: " + ctx.Err().Error()
+	}
+}
+
+// selectTimeout waits up to 1s for a lookup that takes 2s, then for one that takes 0.5s.
+func selectTimeout(ec *exampleContext) {
+	ec.println("Select with a timeout")
+	for _, d := range []time.Duration{2 * time.Second, 500 * time.Millisecond} {
+		ctx, cancel := withClockTimeout(context.Background(), ec.clock, time.Second)
+		result := make(chan string)
+		exited := make(chan string)
+		go slowLookup(ctx, ec, d, result, exited)
+		select {
+		case r := <-result:
+			ec.println(fmt.Sprintf("lookup taking %v: %s", d, r))
+		case <-ctx.Done():
+			ec.println(fmt.Sprintf("lookup taking %v: %v", d, ctx.Err()))
+		}
+		ec.println(<-exited)
+		cancel()
+	}
+}
+
+// selectParentCancel derives three children from one parent and cancels the parent.
+func selectParentCancel(ec *exampleContext) {
+	ec.println("Cancelling a parent cancels its children")
+	parent, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	stopped := make([]string, 3)
+	for i := range stopped {
+		var child context.Context
+		var stop context.CancelFunc
+		if i == 2 {
+			// The last child has its own timeout, but the parent is cancelled first.
+			child, stop = withClockTimeout(parent, ec.clock, time.Hour)
+		} else {
+			child, stop = context.WithCancel(parent)
+		}
+		wg.Add(1)
+		go func(i int, ctx context.Context, stop context.CancelFunc) {
+			defer wg.Done()
+			defer stop()
+			<-ctx.Done()
+			stopped[i] = fmt.Sprintf("child %d stopped: %v", i+1, ctx.Err())
+		}(i, child, stop)
+	}
+
+	ec.clock.Sleep(time.Second)
+	ec.println("cancelling the parent")
+	cancel()
+	wg.Wait()
+	for _, s := range stopped {
+		ec.println(s)
+	}
+}
+
+// selectContextErr shows what ctx.Err() and ctx.Deadline() report over a context's life.
+func selectContextErr(ec *exampleContext) {
+	ec.println("Reading ctx.Err()")
+	ctx, cancel := context.WithCancel(context.Background())
+	ec.println("before cancel:", ctx.Err())
+	cancel()
+	ec.println("after cancel:", ctx.Err())
+	cancel()
+	ec.println("after a second cancel:", ctx.Err())
+
+	start := ec.clock.Now()
+	parent, cancelParent := withClockTimeout(context.Background(), ec.clock, time.Second)
+	defer cancelParent()
+	child, cancelChild := withClockTimeout(parent, ec.clock, time.Minute)
+	defer cancelChild()
+	deadline, _ := child.Deadline()
+	ec.println("child asked for 1m, its deadline is in", deadline.Sub(start).Round(time.Millisecond))
+	<-child.Done()
+	ec.println("child after the parent's deadline:", child.Err())
+	ec.println("parent:", parent.Err())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSelectContextVariantsDoNotLeak(t *testing.T) {
+	for name, variant := range map[string]func(*exampleContext){
+		"select":        selectExample,
+		"deadline":      selectDeadline,
+		"timeout":       selectTimeout,
+		"parent-cancel": selectParentCancel,
+		"err":           selectContextErr,
+	} {
+		before := snapshotGoroutines()
+		ec := newExampleContext(io.Discard)
+		fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+		ec.clock = fc
+		fc.run(func() { variant(ec) })
+		if leaks := before.leaked(time.Second); len(leaks) > 0 {
+			t.Errorf("%s: %s", name, formatLeaks(leaks))
+		}
+	}
+}
+
+func TestClockContextDeadline(t *testing.T) {
+	fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+	ctx, cancel := withClockTimeout(context.Background(), fc, time.Second)
+	defer cancel()
+	child, cancelChild := context.WithCancel(ctx)
+	defer cancelChild()
+
+	if ctx.Err() != nil {
+		t.Fatalf("Err before the deadline = %v", ctx.Err())
+	}
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(fc.Now().Add(time.Second)) {
+		t.Errorf("Deadline() = %v, %v", d, ok)
+	}
+	fc.Advance(999 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("Err 1ms before the deadline = %v", ctx.Err())
+	}
+	fc.Advance(time.Millisecond)
+	<-ctx.Done()
+	<-child.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) || !errors.Is(child.Err(), context.DeadlineExceeded) {
+		t.Errorf("Err = %v, child Err = %v, want %v", ctx.Err(), child.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestClockContextCancel(t *testing.T) {
+	fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := withClockTimeout(parent, fc, time.Second)
+	defer cancel()
+	cancelParent()
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("Err after the parent was cancelled = %v, want %v", ctx.Err(), context.Canceled)
+	}
+	fc.Advance(time.Second)
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("Err changed to %v after the deadline", ctx.Err())
+	}
+
+	ctx, cancel = withClockDeadline(context.Background(), fc, fc.Now())
+	defer cancel()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("Err for a deadline in the past = %v, want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// A slice is a three-word header: a pointer into a backing array, a length and a capacity.
+/* The sections on slices in types_moretypes.go describe what the header does; the
+inspector below prints it after every operation:
+
+	c = c[1:]              c    ptr=0xc000016188 array#4[1]  len=4 cap=4  [0 0 0 0]
+
+Backing arrays are numbered in the order the inspector first sees them, so the
+same number on two slices means they share memory: writing through one is visible
+through the other. When append finds no room left in the capacity it allocates a
+bigger array, copies the elements and returns a header that points there; the
+inspector flags the move. The slices it has seen are kept alive, so the garbage
+collector cannot hand a freed array's address to a new one while it is watching.
+*/
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
+}
+
+// headerOf returns the header of s as the runtime lays it out.
+func headerOf[T any](s []T) sliceHeader {
+	return *(*sliceHeader)(unsafe.Pointer(&s))
+}
+
+// backingArray is one array the inspector has seen, from its first element to the end of its capacity.
+type backingArray struct {
+	id         int
+	start, end uintptr
+}
+
+// sliceInspector prints slice headers and tracks which backing array each named slice uses.
+type sliceInspector struct {
+	ec      *exampleContext
+	arrays  []backingArray // disjoint, in order of id
+	nextID  int
+	current map[string]int // slice name -> array id, 0 for no array
+	keep    []interface{}
+}
+
+func newSliceInspector(ec *exampleContext) *sliceInspector {
+	return &sliceInspector{ec: ec, nextID: 1, current: map[string]int{}}
+}
+
+// overlaps reports whether the bytes from lo to hi are part of a. An empty range, the
+// data of a slice with no capacity, overlaps a if it points into it or at its start.
+func (a backingArray) overlaps(lo, hi uintptr) bool {
+	if lo == hi {
+		return lo >= a.start && lo < a.end || lo == a.start
+	}
+	return lo < a.end && hi > a.start
+}
+
+// arrayOf returns the backing array that holds the size bytes from p. A range that
+// overlaps known arrays extends them, so a view seen before the slice it was cut from
+// is still recognised as sharing its array: the arrays merge into the oldest of them.
+func (in *sliceInspector) arrayOf(p, size uintptr) backingArray {
+	merged := backingArray{start: p, end: p + size}
+	var kept []backingArray
+	at := -1
+	for _, a := range in.arrays {
+		if !a.overlaps(p, p+size) {
+			kept = append(kept, a)
+			continue
+		}
+		if at < 0 {
+			merged.id, at = a.id, len(kept)
+			kept = append(kept, a)
+		} else {
+			for name, id := range in.current {
+				if id == a.id {
+					in.current[name] = merged.id
+				}
+			}
+		}
+		if a.start < merged.start {
+			merged.start = a.start
+		}
+		if a.end > merged.end {
+			merged.end = a.end
+		}
+	}
+	if at < 0 {
+		merged.id = in.nextID
+		in.nextID++
+		kept = append(kept, merged)
+	} else {
+		kept[at] = merged
+	}
+	in.arrays = kept
+	return merged
+}
+
+// inspect prints the header of the slice called name after op, then any sharing or move.
+func inspect[T any](in *sliceInspector, op, name string, s []T) {
+	in.keep = append(in.keep, s)
+	h := headerOf(s)
+	var elem T
+	size := unsafe.Sizeof(elem)
+
+	where := "no array"
+	id := 0
+	if h.data != nil {
+		p := uintptr(h.data)
+		a := in.arrayOf(p, uintptr(h.cap)*size)
+		id = a.id
+		index := uintptr(0)
+		if size > 0 { // every element of a zero-size type is at the same address
+			index = (p - a.start) / size
+		}
+		where = fmt.Sprintf("ptr=%p array#%d[%d]", h.data, a.id, index)
+	}
+	in.ec.println(fmt.Sprintf("%-22s %-4s %s  len=%d cap=%d  %v", op, name, where, h.len, h.cap, s))
+
+	prev, seen := in.current[name]
+	in.current[name] = id
+	if seen && prev != 0 && id != 0 && prev != id {
+		// Only an append allocates a new array for the same slice; any other step
+		// merely pointed name at a different one.
+		msg := fmt.Sprintf("%s now uses array#%d instead of array#%d", name, id, prev)
+		if strings.Contains(op, "append(") {
+			msg = fmt.Sprintf("append moved %s from array#%d to array#%d", name, prev, id)
+		}
+		if left := in.sharing(prev, name); len(left) == 1 {
+			msg += fmt.Sprintf("; %s still uses array#%d", left[0], prev)
+		} else if len(left) > 1 {
+			msg += fmt.Sprintf("; %s still use array#%d", strings.Join(left, ", "), prev)
+		}
+		in.ec.println(fmt.Sprintf("%27s %s", "", msg))
+	}
+	if id == 0 {
+		return
+	}
+	if others := in.sharing(id, name); len(others) > 0 {
+		in.ec.println(fmt.Sprintf("%27s shares array#%d with %s", "", id, strings.Join(others, ", ")))
+	}
+}
+
+// sharing returns the names of the slices other than name that currently use array id, sorted.
+func (in *sliceInspector) sharing(id int, name string) []string {
+	var names []string
+	for other, otherID := range in.current {
+		if other != name && otherID == id {
+			names = append(names, other)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// growthStep is one reallocation seen while appending: at length len the capacity went from oldCap to newCap.
+type growthStep struct {
+	len, oldCap, newCap int
+}
+
+// growthSink makes the slice in capacityGrowth escape. A slice that stays in its function
+// may start in a small stack buffer on newer compilers, which would hide the first steps.
+var growthSink []int
+
+// capacityGrowth appends n ints one at a time to a slice of capacity start and records every
+// time append reallocated. The capacity doubles while the slice is small and grows by about
+// 1.25x past 256 elements, rounded up to the allocator's size classes. The roundings of the
+// first few steps differ between builds, with and without -race, so the example starts at 8.
+func capacityGrowth(start, n int) []growthStep {
+	var steps []growthStep
+	s := make([]int, 0, start)
+	for i := 0; i < n; i++ {
+		before := cap(s)
+		s = append(s, i)
+		if cap(s) != before {
+			steps = append(steps, growthStep{len(s), before, cap(s)})
+		}
+	}
+	growthSink = s
+	growthSink = nil
+	return steps
+}
+
+// growthChart draws the capacity after each reallocation as a bar on a log2 scale.
+func growthChart(steps []growthStep) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%6s %6s %6s  %s\n", "len", "cap", "factor", "log2(cap)")
+	for _, st := range steps {
+		factor := "-"
+		if st.oldCap > 0 {
+			factor = fmt.Sprintf("x%.2f", float64(st.newCap)/float64(st.oldCap))
+		}
+		bar := int(math.Round(math.Log2(float64(st.newCap)) * 3))
+		fmt.Fprintf(&b, "%6d %6d %6s  %s\n", st.len, st.newCap, factor, strings.Repeat("#", bar+1))
+	}
+	return b.String()
+}
+
+// slicesExample runs the slice sections of types_moretypes.go with the inspector watching.
+func slicesExample(ec *exampleContext) {
+	in := newSliceInspector(ec)
+
+	ec.println("Slices share their backing array")
+	names := [4]string{"John", "Paul", "George", "Ringo"}
+	a := names[0:2]
+	b := names[1:3]
+	inspect(in, "a := names[0:2]", "a", a)
+	inspect(in, "b := names[1:3]", "b", b)
+	b[0] = "XXX"
+	inspect(in, `b[0] = "XXX"`, "a", a)
+
+	ec.println("Slice length and capacity")
+	s := []int{2, 3, 5, 7, 11, 13}
+	inspect(in, "s := []int{...}", "s", s)
+	s = s[:0]
+	inspect(in, "s = s[:0]", "s", s)
+	s = s[:4]
+	inspect(in, "s = s[:4]", "s", s)
+	s = s[2:]
+	inspect(in, "s = s[2:]", "s", s)
+	var z []int
+	inspect(in, "var z []int", "z", z)
+
+	ec.println("Creating a slice with make")
+	m := make([]int, 5)
+	inspect(in, "m := make([]int, 5)", "m", m)
+	c := make([]int, 0, 5)
+	inspect(in, "c := make([]int, 0, 5)", "c", c)
+	c = c[:cap(c)]
+	inspect(in, "c = c[:cap(c)]", "c", c)
+	c = c[1:]
+	inspect(in, "c = c[1:]", "c", c)
+
+	ec.println("Appending to a slice")
+	var p []int
+	inspect(in, "var p []int", "p", p)
+	p = append(p, 0)
+	inspect(in, "p = append(p, 0)", "p", p)
+	p = append(p, 1)
+	inspect(in, "p = append(p, 1)", "p", p)
+	p = append(p, 2, 3, 4)
+	inspect(in, "p = append(p, 2, 3, 4)", "p", p)
+
+	ec.println("Two appends to the same slice with spare capacity")
+	x := make([]int, 3, 4)
+	inspect(in, "x := make([]int, 3, 4)", "x", x)
+	y := append(x, 1)
+	inspect(in, "y := append(x, 1)", "y", y)
+	w := append(x, 2)
+	inspect(in, "w := append(x, 2)", "w", w)
+	inspect(in, "y after w's append", "y", y)
+	y = append(y, 5)
+	inspect(in, "y = append(y, 5)", "y", y)
+
+	ec.println("Capacity growth over 2000 appends to make([]int, 0, 8)")
+	ec.printf("%s", growthChart(capacityGrowth(8, 2000)))
+}
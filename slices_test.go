@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSliceInspector(t *testing.T) {
+	var out bytes.Buffer
+	in := newSliceInspector(newExampleContext(&out))
+
+	base := make([]int, 2, 3)
+	inspect(in, "base", "base", base)
+	view := base[1:]
+	inspect(in, "view := base[1:]", "view", view)
+	grown := append(view, 1, 2, 3)
+	inspect(in, "view = append(view, 1, 2, 3)", "view", grown)
+
+	for _, want := range []string{
+		"array#1[0]  len=2 cap=3",
+		"array#1[1]  len=1 cap=2",
+		"shares array#1 with base",
+		"append moved view from array#1 to array#2; base still uses array#1",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("inspector output is missing %q:\n%s", want, out.String())
+		}
+	}
+	other := []int{7, 8}
+	inspect(in, "view = other[1:]", "view", other[1:])
+	if strings.Contains(out.String(), "append moved view from array#2") {
+		t.Errorf("reslicing another array was reported as an append:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "view now uses array#3 instead of array#2") {
+		t.Errorf("inspector output is missing the switch to array#3:\n%s", out.String())
+	}
+	if h := headerOf(view); h.len != 1 || h.cap != 2 {
+		t.Errorf("headerOf(view) = len %d cap %d, want len 1 cap 2", h.len, h.cap)
+	}
+}
+
+// TestSliceInspectorViewFirst inspects views of an array before the whole array, and
+// a slice of a zero-size type.
+func TestSliceInspectorViewFirst(t *testing.T) {
+	var out bytes.Buffer
+	in := newSliceInspector(newExampleContext(&out))
+
+	arr := make([]int, 8)
+	inspect(in, "tail := arr[5:]", "tail", arr[5:])
+	inspect(in, "mid := arr[2:4:4]", "mid", arr[2:4:4])
+	inspect(in, "arr", "arr", arr)
+	inspect(in, "e", "e", make([]struct{}, 3))
+
+	for _, want := range []string{
+		"tail ptr=<addr> array#1[0]",
+		"mid  ptr=<addr> array#2[0]",
+		"arr  ptr=<addr> array#1[0]  len=8 cap=8",
+		"shares array#1 with mid, tail",
+		"e    ptr=<addr> array#3[0]  len=3 cap=3",
+	} {
+		if !strings.Contains(addrPattern.ReplaceAllString(out.String(), "ptr=<addr>"), want) {
+			t.Errorf("inspector output is missing %q:\n%s", want, out.String())
+		}
+	}
+	if len(in.arrays) != 2 || in.current["mid"] != 1 {
+		t.Errorf("arrays %v, mid on array#%d; want arr, mid and tail merged into array#1", in.arrays, in.current["mid"])
+	}
+}
+
+var addrPattern = regexp.MustCompile(`ptr=0x[0-9a-f]+`)
+
+func TestCapacityGrowth(t *testing.T) {
+	steps := capacityGrowth(0, 5000)
+	if len(steps) == 0 || steps[0].len != 1 || steps[0].oldCap != 0 {
+		t.Fatalf("first step = %+v, want the first append to allocate", steps)
+	}
+	for i, st := range steps {
+		if st.newCap < st.len {
+			t.Errorf("step %d: cap %d is below len %d", i, st.newCap, st.len)
+		}
+		if i > 0 && st.oldCap != steps[i-1].newCap {
+			t.Errorf("step %d grew from %d, but the previous step left cap %d", i, st.oldCap, steps[i-1].newCap)
+		}
+		// Past 256 elements append grows by at least a quarter, never more than double.
+		if st.oldCap >= 256 && (st.newCap < st.oldCap+st.oldCap/4 || st.newCap > 2*st.oldCap) {
+			t.Errorf("step %d: cap %d -> %d is outside 1.25x..2x", i, st.oldCap, st.newCap)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Package sync provides basic synchronization primitives such as mutual exclusion locks.
+/* Channels pass data between goroutines; the sync package protects data that goroutines share.
+
+  - sync.WaitGroup waits for a collection of goroutines to finish.
+  - sync.Mutex lets one goroutine at a time into a critical section.
+  - sync.RWMutex lets many readers, or one writer, in at a time.
+  - sync.Once runs a function exactly once, however many goroutines ask.
+  - sync.Cond lets goroutines wait until a condition on shared state becomes true.
+  - sync.Map is a map that is safe for concurrent use without extra locking.
+  - sync.Pool keeps temporary objects around for reuse, to take pressure off the garbage collector.
+  - sync/atomic offers lock-free operations on single words of memory.
+
+Each function below has a broken counterpart in sync_racedemo_test.go. Run
+
+	go test -race -tags racedemo -run Racy .
+
+and the race detector reports a data race for every one of them.
+*/
+func syncExample(ec *exampleContext) {
+	ec.step("WaitGroup", func() { ec.println("WaitGroup: sum of squares 1..5 =", sumSquaresWaitGroup(5)) })
+	ec.step("Mutex", func() { ec.println("Mutex: 50 goroutines x 1000 increments =", countWithMutex(50, 1000)) })
+	ec.step("RWMutex", func() { ec.println("RWMutex:", readMostlyCache()) })
+	ec.step("Once", func() { ec.println("Once: 10 goroutines asked, config loaded", loadConfigOnce(10), "time(s)") })
+	ec.step("Cond", func() {
+		ec.println("Cond:", condQueue(5))
+		ec.println("Cond broadcast: started", condStartGate(4), "workers")
+	})
+	ec.step("sync.Map", func() {
+		ec.println("sync.Map:", countWordsSyncMap([]string{"go", "chan", "go", "select", "chan", "go"}))
+	})
+	ec.step("Pool", func() { ec.println("Pool:", formatWithPool([]int{1, 2, 3})) })
+	ec.step("atomic", func() {
+		ec.println("atomic: counter =", countWithAtomic(50, 1000), "max =", atomicMax([]int64{3, 41, 7, 19}))
+	})
+}
+
+// sumSquaresWaitGroup squares 1..n in n goroutines and waits for all of them with a WaitGroup.
+// Add is called before each goroutine starts, never inside it, so Wait cannot run too early.
+func sumSquaresWaitGroup(n int) int {
+	squares := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			squares[i] = (i + 1) * (i + 1)
+		}(i)
+	}
+	wg.Wait()
+
+	sum := 0
+	for _, s := range squares {
+		sum += s
+	}
+	return sum
+}
+
+// safeCounter guards n with a Mutex; the mutex sits next to the field it protects.
+type safeCounter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *safeCounter) inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+}
+
+// countWithMutex increments one counter from many goroutines.
+func countWithMutex(goroutines, increments int) int {
+	var c safeCounter
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				c.inc()
+			}
+		}()
+	}
+	wg.Wait()
+	return c.n
+}
+
+// rwCache is read far more often than it is written, so readers share an RLock.
+type rwCache struct {
+	mu sync.RWMutex
+	m  map[string]int
+}
+
+func (c *rwCache) get(key string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.m[key]
+	return v, ok
+}
+
+func (c *rwCache) set(key string, v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = v
+}
+
+// readMostlyCache runs 8 readers against 1 writer and reports what the readers saw.
+func readMostlyCache() string {
+	c := &rwCache{m: map[string]int{"version": 0}}
+	var wg sync.WaitGroup
+	var reads int64
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, ok := c.get("version"); ok {
+					atomic.AddInt64(&reads, 1)
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := 1; v <= 10; v++ {
+			c.set("version", v)
+		}
+	}()
+	wg.Wait()
+	v, _ := c.get("version")
+	return fmt.Sprintf("%d reads, final version %d", reads, v)
+}
+
+// loadConfigOnce has n goroutines ask for a lazily loaded config and returns how often it was loaded.
+func loadConfigOnce(n int) int {
+	var once sync.Once
+	var config map[string]string
+	loads := 0
+	load := func() {
+		loads++
+		config = map[string]string{"env": "dev"}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			once.Do(load)
+			_ = config["env"] // Do returns only after load has finished
+		}()
+	}
+	wg.Wait()
+	return loads
+}
+
+// condQueue hands n items from a producer to a consumer that waits on a Cond while the queue is empty.
+func condQueue(n int) []int {
+	var mu sync.Mutex
+	notEmpty := sync.NewCond(&mu)
+	var queue, got []int
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mu.Lock()
+		defer mu.Unlock()
+		for len(got) < n {
+			// Wait unlocks mu while it sleeps. The condition is checked in a loop:
+			// it may no longer hold by the time Wait returns.
+			for len(queue) == 0 {
+				notEmpty.Wait()
+			}
+			got = append(got, queue[0])
+			queue = queue[1:]
+		}
+	}()
+
+	for i := 1; i <= n; i++ {
+		mu.Lock()
+		queue = append(queue, i*10)
+		mu.Unlock()
+		notEmpty.Signal()
+	}
+	<-done
+	return got
+}
+
+// condStartGate parks n workers until a single Broadcast releases all of them.
+func condStartGate(n int) int {
+	var mu sync.Mutex
+	gate := sync.NewCond(&mu)
+	open := false
+	waiting, started := 0, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			waiting++
+			gate.Broadcast() // wake the opener, which waits for every worker to arrive
+			for !open {
+				gate.Wait()
+			}
+			started++
+		}()
+	}
+
+	mu.Lock()
+	for waiting < n {
+		gate.Wait()
+	}
+	open = true
+	mu.Unlock()
+	gate.Broadcast()
+	wg.Wait()
+	return started
+}
+
+// countWordsSyncMap counts words from one goroutine per word using a sync.Map of *int64 counters.
+func countWordsSyncMap(words []string) string {
+	var counts sync.Map
+	var wg sync.WaitGroup
+	for _, w := range words {
+		wg.Add(1)
+		go func(w string) {
+			defer wg.Done()
+			n, _ := counts.LoadOrStore(w, new(int64))
+			atomic.AddInt64(n.(*int64), 1)
+		}(w)
+	}
+	wg.Wait()
+
+	var out []string
+	counts.Range(func(k, v interface{}) bool {
+		out = append(out, fmt.Sprintf("%s=%d", k, atomic.LoadInt64(v.(*int64))))
+		return true
+	})
+	sort.Strings(out)
+	return fmt.Sprint(out)
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// formatWithPool formats each value with a buffer from bufferPool. The string is copied
+// out before the buffer goes back: after Put another goroutine may already be using it.
+func formatWithPool(values []int) []string {
+	out := make([]string, len(values))
+	var wg sync.WaitGroup
+	for i, v := range values {
+		wg.Add(1)
+		go func(i, v int) {
+			defer wg.Done()
+			buf := bufferPool.Get().(*bytes.Buffer)
+			buf.Reset()
+			fmt.Fprintf(buf, "item-%03d", v)
+			out[i] = buf.String()
+			bufferPool.Put(buf)
+		}(i, v)
+	}
+	wg.Wait()
+	return out
+}
+
+// countWithAtomic increments one int64 from many goroutines with atomic.AddInt64.
+// Every access, reads included, goes through sync/atomic.
+func countWithAtomic(goroutines, increments int) int64 {
+	var n int64
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				atomic.AddInt64(&n, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	return atomic.LoadInt64(&n)
+}
+
+// atomicMax records the largest value seen by several goroutines with a CompareAndSwap loop.
+func atomicMax(values []int64) int64 {
+	var largest int64
+	var wg sync.WaitGroup
+	for _, v := range values {
+		wg.Add(1)
+		go func(v int64) {
+			defer wg.Done()
+			for {
+				cur := atomic.LoadInt64(&largest)
+				if v <= cur || atomic.CompareAndSwapInt64(&largest, cur, v) {
+					return
+				}
+			}
+		}(v)
+	}
+	wg.Wait()
+	return atomic.LoadInt64(&largest)
+}
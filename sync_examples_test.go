@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestSyncExamples runs every primitive's example several times; under -race it
+// also checks that none of them has a data race.
+func TestSyncExamples(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		if got := sumSquaresWaitGroup(10); got != 385 {
+			t.Fatalf("sumSquaresWaitGroup(10) = %d, want 385", got)
+		}
+		if got := countWithMutex(20, 500); got != 10000 {
+			t.Fatalf("countWithMutex = %d, want 10000", got)
+		}
+		if got := readMostlyCache(); got != "800 reads, final version 10" {
+			t.Fatalf("readMostlyCache = %q", got)
+		}
+		if got := loadConfigOnce(10); got != 1 {
+			t.Fatalf("config loaded %d times, want once", got)
+		}
+		if got := fmt.Sprint(condQueue(10)); got != "[10 20 30 40 50 60 70 80 90 100]" {
+			t.Fatalf("condQueue(10) = %s", got)
+		}
+		if got := condStartGate(8); got != 8 {
+			t.Fatalf("condStartGate(8) started %d workers", got)
+		}
+		if got := countWordsSyncMap([]string{"a", "b", "a"}); got != "[a=2 b=1]" {
+			t.Fatalf("countWordsSyncMap = %s", got)
+		}
+		if got := fmt.Sprint(formatWithPool([]int{4, 5})); got != "[item-004 item-005]" {
+			t.Fatalf("formatWithPool = %s", got)
+		}
+		if got := countWithAtomic(20, 500); got != 10000 {
+			t.Fatalf("countWithAtomic = %d, want 10000", got)
+		}
+		if got := atomicMax([]int64{5, -3, 99, 12}); got != 99 {
+			t.Fatalf("atomicMax = %d, want 99", got)
+		}
+	}
+}
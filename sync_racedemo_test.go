@@ -0,0 +1,153 @@
+//go:build racedemo
+
+package main
+
+// Broken counterparts of the examples in sync_examples.go. Each one shares memory
+// without the synchronization its correct version uses, so
+//
+//	go test -race -tags racedemo -run Racy .
+//
+// fails every test below with "WARNING: DATA RACE". Without -race they usually pass:
+// a data race does not have to produce a wrong answer to be a bug.
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Sleeping is not synchronization: nothing orders the writes before the reads.
+func TestRacyWaitGroupSleep(t *testing.T) {
+	squares := make([]int, 5)
+	for i := range squares {
+		go func(i int) { squares[i] = (i + 1) * (i + 1) }(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	sum := 0
+	for _, s := range squares {
+		sum += s
+	}
+	t.Log("sum", sum)
+}
+
+// c.n++ is a read, an add and a write; two goroutines can interleave them.
+func TestRacyMutexMissing(t *testing.T) {
+	n := 0
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				n++
+			}
+		}()
+	}
+	wg.Wait()
+	t.Log("count", n)
+}
+
+// A write under RLock runs alongside other readers and writers.
+func TestRacyRWMutexWriteUnderRLock(t *testing.T) {
+	var mu sync.RWMutex
+	version := 0
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.RLock()
+			version++
+			mu.RUnlock()
+		}()
+	}
+	wg.Wait()
+	t.Log("version", version)
+}
+
+// Check-then-act lazy initialisation: several goroutines can see config == nil.
+func TestRacyOnceCheckThenAct(t *testing.T) {
+	var config map[string]string
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if config == nil {
+				config = map[string]string{"env": "dev"}
+			}
+		}()
+	}
+	wg.Wait()
+	t.Log("config", config)
+}
+
+// The condition is read without holding the Cond's lock.
+func TestRacyCondWithoutLock(t *testing.T) {
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	ready := false
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for !ready {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	ready = true
+	cond.Broadcast()
+	<-done
+}
+
+// A plain map shared between goroutines, which sync.Map or a Mutex would protect.
+func TestRacyPlainMap(t *testing.T) {
+	counts := map[string]int{}
+	var wg sync.WaitGroup
+	for _, w := range []string{"go", "chan"} {
+		wg.Add(1)
+		go func(w string) {
+			defer wg.Done()
+			counts[w]++
+		}(w)
+	}
+	wg.Wait()
+	t.Log("counts", counts)
+}
+
+// The buffer is still used after Put, while another goroutine may have taken it from the pool.
+func TestRacyPoolUseAfterPut(t *testing.T) {
+	pool := sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				buf := pool.Get().(*bytes.Buffer)
+				buf.Reset()
+				buf.WriteString("item")
+				pool.Put(buf)
+				_ = buf.String()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Atomic writes do not make plain reads of the same variable safe.
+func TestRacyAtomicMixedWithPlainRead(t *testing.T) {
+	var n int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			atomic.AddInt64(&n, 1)
+		}
+	}()
+	for i := 0; i < 1000 && n < 1000; i++ {
+	}
+	<-done
+	t.Log("count", n)
+}
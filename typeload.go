@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// loadedPackage is a package parsed and type-checked from source, for the tools that
+// inspect this module's types.
+/* The tools need nothing beyond the standard library: go/parser reads the files and
+go/types checks them, importing dependencies with the "source" importer, which
+type-checks them from their source under GOROOT the same way. That is slower than
+reading compiled export data, a few seconds for net/http, but works whatever the
+toolchain has installed.
+
+A single file can be loaded on its own. It is then checked without the rest of its
+package, so names it uses from other files are errors; they are kept in errs. The
+types the file declares are still complete enough to lay out and compare, except
+where a field's type is one of those missing names, which the tools report.
+*/
+type loadedPackage struct {
+	fset  *token.FileSet
+	files []*ast.File
+	pkg   *types.Package
+	info  *types.Info
+	sizes types.Sizes // sizes on the architecture this program runs on
+	errs  []error     // type errors; the package is usable despite them
+}
+
+// loadPackage parses and type-checks path: a directory, whose non-test .go files
+// make up the package, or a single .go file.
+func loadPackage(path string) (*loadedPackage, error) {
+	paths := []string{path}
+	if st, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if st.IsDir() {
+		all, err := filepath.Glob(filepath.Join(path, "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		paths = paths[:0]
+		for _, p := range all {
+			if !strings.HasSuffix(p, "_test.go") {
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no Go files in %s", path)
+		}
+	}
+
+	lp := &loadedPackage{
+		fset:  token.NewFileSet(),
+		sizes: types.SizesFor("gc", runtime.GOARCH),
+		info: &types.Info{
+			Defs:  map[*ast.Ident]types.Object{},
+			Types: map[ast.Expr]types.TypeAndValue{},
+		},
+	}
+	for _, p := range paths {
+		f, err := parser.ParseFile(lp.fset, p, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		lp.files = append(lp.files, f)
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(lp.fset, "source", nil),
+		Sizes:    lp.sizes,
+		Error:    func(err error) { lp.errs = append(lp.errs, err) },
+	}
+	lp.pkg, _ = conf.Check(lp.files[0].Name.Name, lp.fset, lp.files, lp.info)
+	return lp, nil
+}
+
+// namedTypes returns the named types declared at package level, sorted by name.
+func (lp *loadedPackage) namedTypes() []*types.TypeName {
+	scope := lp.pkg.Scope()
+	var out []*types.TypeName
+	for _, name := range scope.Names() {
+		if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() {
+			out = append(out, tn)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+// lookupType returns the package-level type called name.
+func (lp *loadedPackage) lookupType(name string) (*types.TypeName, error) {
+	tn, ok := lp.pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("no type %s in package %s", name, lp.pkg.Name())
+	}
+	return tn, nil
+}
+
+// isGeneric reports whether tn is a generic type, whose layout and method sets depend
+// on the type arguments it is instantiated with.
+func isGeneric(tn *types.TypeName) bool {
+	named, ok := tn.Type().(*types.Named)
+	return ok && named.TypeParams().Len() > 0
+}
+
+// errorAt returns the message of the first type error on the line of pos, or "" if
+// there is none there.
+func (lp *loadedPackage) errorAt(pos token.Pos) string {
+	at := lp.fset.Position(pos)
+	for _, err := range lp.errs {
+		if terr, ok := err.(types.Error); ok {
+			if p := lp.fset.Position(terr.Pos); p.Filename == at.Filename && p.Line == at.Line {
+				return terr.Msg
+			}
+		}
+	}
+	return ""
+}
@@ -19,6 +19,9 @@ A struct is a collection of fields.
 Struct fields are accessed using a dot.
 Struct fields can be accessed through a struct pointer.
 A struct literal denotes a newly allocated struct value by listing the values of its fields.
+
+The order of the fields decides how much padding the struct carries; see layout.go and
+"go-concepts layout paddedRecord".
 */
 
 /* Arrays
@@ -89,6 +92,8 @@ It is common to append new elements to a slice, and so Go provides a built-in ap
 The first parameter s of append is a slice of type T, and the rest are T values to append to the slice.
 The resulting value of append is a slice containing all the elements of the original slice plus the provided values.
 If the backing array of s is too small to fit all the given values a bigger array will be allocated. The returned slice will point to the newly allocated array.
+
+"go-concepts run slices" prints the slice header after each of the operations above.
 */
 
 /* Range
@@ -129,4 +134,7 @@ Test that a key is present with a two-value assignment:
 	elem, ok = m[key]
 If key is in m, ok is true. If not, ok is false.
 If key is not in the map, then elem is the zero value for the map's element type.
+
+"go-concepts run map-internals" runs these operations on a simulated map (map_sim.go)
+and prints its buckets after each one.
 */
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A worker pool runs jobs on a bounded number of goroutines fed from a bounded queue.
+/* Starting one goroutine per job is cheap, but it puts no limit on how many jobs run at
+once or how many wait. A pool keeps both bounded:
+
+	jobs ──> [ queue: buffered channel ] ──> worker 1 ─┐
+	                                    ├──> worker 2 ─┼──> results
+	                                    └──> worker N ─┘
+
+  - Submit blocks once the queue is full, which slows producers down to the pool's speed.
+  - A fixed pool always runs maxWorkers goroutines. A dynamic pool starts with
+    minWorkers, starts another worker whenever more jobs are outstanding than there are
+    workers, and retires extra workers after idleTimeout without a job.
+  - Results are delivered in completion order, or in submission order when ordered is
+    set: results that finish early wait until every earlier job has been delivered.
+  - A job that panics does not take the pool down: the panic is recovered and
+    reported as that job's error.
+  - Shutdown stops accepting jobs, lets the workers drain the queue and closes Results.
+    If its context ends first, the remaining jobs are cancelled instead. A Submit
+    waiting for room in the queue gives up with errPoolClosed as soon as Shutdown
+    is called.
+
+Results must be read while the pool runs: a worker waits until its result is received.
+*/
+type poolConfig struct {
+	minWorkers  int
+	maxWorkers  int
+	queueSize   int
+	ordered     bool
+	idleTimeout time.Duration
+}
+
+type poolResult[R any] struct {
+	job   int // position of the job in submission order, from 0
+	value R
+	err   error
+}
+
+// poolMetrics is a snapshot of a pool's counters.
+type poolMetrics struct {
+	queued      int
+	active      int
+	workers     int
+	peakWorkers int
+	submitted   int
+	completed   int
+	failed      int
+	panicked    int
+	elapsed     time.Duration
+}
+
+// throughput is the number of completed jobs per second since the pool started.
+func (m poolMetrics) throughput() float64 {
+	if m.elapsed <= 0 {
+		return 0
+	}
+	return float64(m.completed) / m.elapsed.Seconds()
+}
+
+var errPoolClosed = errors.New("worker pool is shut down")
+
+type poolJob[T any] struct {
+	seq   int
+	input T
+}
+
+type workerPool[T, R any] struct {
+	cfg     poolConfig
+	clock   clock
+	fn      func(ctx context.Context, input T) (R, error)
+	queue   chan poolJob[T]
+	done    chan poolResult[R]
+	results chan poolResult[R]
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started time.Time
+	workers sync.WaitGroup
+	drained chan struct{}
+
+	// submitMu is held for reading while a job is submitted, and for writing to close the queue.
+	// closing is closed first, so that submitters blocked on a full queue let go of it.
+	submitMu    sync.RWMutex
+	closed      bool
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	mu sync.Mutex
+	m  poolMetrics
+}
+
+// newWorkerPool starts a pool that runs fn on each submitted input. A zero minWorkers
+// gives a fixed pool of maxWorkers.
+func newWorkerPool[T, R any](clk clock, cfg poolConfig, fn func(ctx context.Context, input T) (R, error)) *workerPool[T, R] {
+	if cfg.maxWorkers < 1 {
+		cfg.maxWorkers = 1
+	}
+	if cfg.minWorkers <= 0 || cfg.minWorkers > cfg.maxWorkers {
+		cfg.minWorkers = cfg.maxWorkers
+	}
+	p := &workerPool[T, R]{
+		cfg:     cfg,
+		clock:   clk,
+		fn:      fn,
+		queue:   make(chan poolJob[T], cfg.queueSize),
+		done:    make(chan poolResult[R], cfg.maxWorkers),
+		results: make(chan poolResult[R], cfg.queueSize),
+		started: clk.Now(),
+		drained: make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.mu.Lock()
+	for i := 0; i < cfg.minWorkers; i++ {
+		p.startWorker()
+	}
+	p.mu.Unlock()
+	go p.deliver()
+	go func() {
+		p.workers.Wait()
+		close(p.done)
+		close(p.drained)
+	}()
+	return p
+}
+
+// Results returns the channel the results are delivered on. It is closed after Shutdown.
+func (p *workerPool[T, R]) Results() <-chan poolResult[R] { return p.results }
+
+// Submit queues input, blocking while the queue is full. It returns the job's position
+// in submission order.
+func (p *workerPool[T, R]) Submit(ctx context.Context, input T) (int, error) {
+	select {
+	case <-p.closing:
+		return 0, errPoolClosed
+	default:
+	}
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+	if p.closed {
+		return 0, errPoolClosed
+	}
+
+	p.mu.Lock()
+	seq := p.m.submitted
+	p.m.submitted++
+	outstanding := p.m.submitted - p.m.completed - p.m.failed
+	if outstanding > p.m.workers && p.m.workers < p.cfg.maxWorkers {
+		p.startWorker()
+	}
+	p.mu.Unlock()
+
+	var err error
+	select {
+	case p.queue <- poolJob[T]{seq: seq, input: input}:
+		return seq, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-p.closing:
+		err = errPoolClosed
+	case <-p.ctx.Done():
+		err = errPoolClosed
+	}
+	// The job was counted but never queued: report it as failed so ordered delivery moves on.
+	p.mu.Lock()
+	p.m.failed++
+	p.mu.Unlock()
+	p.done <- poolResult[R]{job: seq, err: err}
+	return 0, err
+}
+
+// Shutdown stops accepting jobs and waits for the queued ones to finish. If ctx ends
+// first, the jobs still running or queued are cancelled and ctx.Err() is returned.
+func (p *workerPool[T, R]) Shutdown(ctx context.Context) error {
+	p.closingOnce.Do(func() { close(p.closing) })
+	// A submitter may still hold submitMu while it reports its job as failed, so the
+	// queue is closed in the background: the wait below gives up when ctx ends.
+	go func() {
+		p.submitMu.Lock()
+		if !p.closed {
+			p.closed = true
+			close(p.queue)
+		}
+		p.submitMu.Unlock()
+	}()
+
+	select {
+	case <-p.drained:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// Metrics returns a snapshot of the pool's counters.
+func (p *workerPool[T, R]) Metrics() poolMetrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := p.m
+	m.queued = len(p.queue)
+	m.elapsed = p.clock.Now().Sub(p.started)
+	return m
+}
+
+// startWorker starts one more worker. p.mu must be held.
+func (p *workerPool[T, R]) startWorker() {
+	p.m.workers++
+	if p.m.workers > p.m.peakWorkers {
+		p.m.peakWorkers = p.m.workers
+	}
+	p.workers.Add(1)
+	go p.work()
+}
+
+// retire stops the calling worker if the pool has more than minWorkers.
+func (p *workerPool[T, R]) retire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.m.workers <= p.cfg.minWorkers {
+		return false
+	}
+	p.m.workers--
+	return true
+}
+
+func (p *workerPool[T, R]) work() {
+	defer p.workers.Done()
+	for {
+		var idle <-chan time.Time
+		var timer clockTimer
+		if p.cfg.idleTimeout > 0 && p.cfg.minWorkers < p.cfg.maxWorkers {
+			timer = p.clock.NewTimer(p.cfg.idleTimeout)
+			idle = timer.C()
+		}
+		select {
+		case job, ok := <-p.queue:
+			if timer != nil {
+				timer.Stop()
+			}
+			if !ok {
+				p.mu.Lock()
+				p.m.workers--
+				p.mu.Unlock()
+				return
+			}
+			p.done <- p.run(job)
+		case <-idle:
+			if p.retire() {
+				return
+			}
+		}
+	}
+}
+
+// run calls fn for one job, turning a panic into the job's error.
+func (p *workerPool[T, R]) run(job poolJob[T]) (res poolResult[R]) {
+	p.mu.Lock()
+	p.m.active++
+	p.mu.Unlock()
+
+	res.job = job.seq
+	defer func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.m.active--
+		if r := recover(); r != nil {
+			res.err = fmt.Errorf("job %d panicked: %v", job.seq, r)
+			p.m.panicked++
+		}
+		if res.err != nil {
+			p.m.failed++
+		} else {
+			p.m.completed++
+		}
+	}()
+
+	if err := p.ctx.Err(); err != nil {
+		res.err = err
+		return res
+	}
+	res.value, res.err = p.fn(p.ctx, job.input)
+	return res
+}
+
+// deliver forwards finished jobs to Results, reordering them first if the pool is ordered.
+func (p *workerPool[T, R]) deliver() {
+	defer close(p.results)
+	pending := map[int]poolResult[R]{}
+	next := 0
+	for res := range p.done {
+		if !p.cfg.ordered {
+			p.results <- res
+			continue
+		}
+		pending[res.job] = res
+		for r, ok := pending[next]; ok; r, ok = pending[next] {
+			p.results <- r
+			delete(pending, next)
+			next++
+		}
+	}
+}
+
+// countPrimes counts the primes below n by trial division: a CPU-bound job.
+func countPrimes(n int) int {
+	count := 0
+	for i := 2; i < n; i++ {
+		prime := true
+		for d := 2; d*d <= i; d++ {
+			if i%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			count++
+		}
+	}
+	return count
+}
+
+func workerPoolExample(ec *exampleContext) {
+	ctx := context.Background()
+
+	ec.println("CPU-bound jobs on a fixed pool of 4 workers, results in submission order")
+	cpu := newWorkerPool(ec.clock, poolConfig{maxWorkers: 4, queueSize: 4, ordered: true},
+		func(ctx context.Context, n int) (int, error) {
+			if n < 0 {
+				var table []int
+				return table[n], nil // panics: the pool recovers it
+			}
+			return countPrimes(n), nil
+		})
+	inputs := []int{1000, 20000, 10, 5000, -1, 100000, 50}
+	go func() {
+		for _, n := range inputs {
+			cpu.Submit(ctx, n)
+		}
+		cpu.Shutdown(ctx)
+	}()
+	for res := range cpu.Results() {
+		if res.err != nil {
+			ec.println(fmt.Sprintf("job %d: %v", res.job, res.err))
+			continue
+		}
+		ec.println(fmt.Sprintf("job %d: %d primes below %d", res.job, res.value, inputs[res.job]))
+	}
+	m := cpu.Metrics()
+	ec.println(fmt.Sprintf("completed=%d failed=%d panicked=%d workers=%d", m.completed, m.failed, m.panicked, m.workers))
+
+	ec.println("IO-bound jobs on a dynamic pool of 1 to 8 workers, results as they finish")
+	fetch := newWorkerPool(ec.clock, poolConfig{minWorkers: 1, maxWorkers: 8, queueSize: 4, idleTimeout: time.Second},
+		func(ctx context.Context, id int) (string, error) {
+			select {
+			case <-ec.clock.After(200 * time.Millisecond): // a slow network call
+				return fmt.Sprintf("response %d", id), nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		})
+	var responses []string
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for res := range fetch.Results() {
+			responses = append(responses, res.value)
+		}
+	}()
+	for id := 0; id < 16; id++ {
+		fetch.Submit(ctx, id)
+	}
+	for fetch.Metrics().completed < 16 {
+		ec.clock.Sleep(100 * time.Millisecond)
+	}
+	m = fetch.Metrics()
+	ec.println(fmt.Sprintf("completed=%d peak workers=%d throughput=%.0f jobs/s", m.completed, m.peakWorkers, m.throughput()))
+	ec.clock.Sleep(2 * time.Second)
+	ec.println("workers after 2s idle:", fetch.Metrics().workers)
+	ec.println("shutdown:", fetch.Shutdown(ctx))
+	<-collected
+	sort.Strings(responses)
+	ec.println(len(responses), "responses, first", responses[0], "last", responses[len(responses)-1])
+
+	_, err := fetch.Submit(ctx, 99)
+	ec.println("submit after shutdown:", err)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolOrderedResults(t *testing.T) {
+	before := snapshotGoroutines()
+	rng := rand.New(rand.NewSource(1))
+	delays := make([]time.Duration, 50)
+	for i := range delays {
+		delays[i] = time.Duration(rng.Intn(2000)) * time.Microsecond
+	}
+
+	var mu sync.Mutex
+	active, peak := 0, 0
+	pool := newWorkerPool(realClock{}, poolConfig{maxWorkers: 4, queueSize: 2, ordered: true},
+		func(ctx context.Context, i int) (int, error) {
+			mu.Lock()
+			active++
+			if active > peak {
+				peak = active
+			}
+			mu.Unlock()
+			time.Sleep(delays[i])
+			mu.Lock()
+			active--
+			mu.Unlock()
+			if i == 7 {
+				panic("job 7 failed")
+			}
+			return i * i, nil
+		})
+	go func() {
+		for i := range delays {
+			if _, err := pool.Submit(context.Background(), i); err != nil {
+				t.Error(err)
+			}
+		}
+		if err := pool.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	next := 0
+	for res := range pool.Results() {
+		if res.job != next {
+			t.Fatalf("got job %d, want %d", res.job, next)
+		}
+		if next == 7 {
+			if res.err == nil {
+				t.Errorf("job 7 panicked but has no error")
+			}
+		} else if res.err != nil || res.value != next*next {
+			t.Errorf("job %d = %d, %v", next, res.value, res.err)
+		}
+		next++
+	}
+	if next != len(delays) {
+		t.Errorf("got %d results, want %d", next, len(delays))
+	}
+	if peak > 4 {
+		t.Errorf("%d jobs ran at once on 4 workers", peak)
+	}
+	m := pool.Metrics()
+	if m.completed != 49 || m.failed != 1 || m.panicked != 1 || m.workers != 0 {
+		t.Errorf("metrics after shutdown: %+v", m)
+	}
+	if leaks := before.leaked(time.Second); len(leaks) > 0 {
+		t.Error(formatLeaks(leaks))
+	}
+}
+
+func TestWorkerPoolSubmitBlocksWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	pool := newWorkerPool(realClock{}, poolConfig{maxWorkers: 1, queueSize: 1},
+		func(ctx context.Context, i int) (int, error) {
+			<-release
+			return i, nil
+		})
+	go func() {
+		for range pool.Results() {
+		}
+	}()
+	for i := 0; i < 2; i++ { // one running, one queued
+		if _, err := pool.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Submit(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Submit on a full queue returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if q := pool.Metrics().queued; q != 1 {
+		t.Errorf("queue depth %d, want 1", q)
+	}
+	close(release)
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Submit(context.Background(), 3); !errors.Is(err, errPoolClosed) {
+		t.Errorf("Submit after Shutdown returned %v, want %v", err, errPoolClosed)
+	}
+}
+
+// TestWorkerPoolShutdownReleasesBlockedSubmit checks that Shutdown does not wait for a
+// Submit blocked on a full queue: the Submit fails and the queued jobs still run.
+func TestWorkerPoolShutdownReleasesBlockedSubmit(t *testing.T) {
+	release := make(chan struct{})
+	pool := newWorkerPool(realClock{}, poolConfig{maxWorkers: 1, queueSize: 1},
+		func(ctx context.Context, i int) (int, error) {
+			<-release
+			return i, nil
+		})
+	go func() {
+		for range pool.Results() {
+		}
+	}()
+	for i := 0; i < 2; i++ { // one running, one queued
+		if _, err := pool.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	submitted := make(chan error)
+	go func() {
+		_, err := pool.Submit(context.Background(), 2)
+		submitted <- err
+	}()
+	for pool.Metrics().submitted < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	shutdown := make(chan error)
+	go func() { shutdown <- pool.Shutdown(context.Background()) }()
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, errPoolClosed) {
+			t.Errorf("blocked Submit returned %v, want %v", err, errPoolClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not release the blocked Submit")
+	}
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if m := pool.Metrics(); m.completed != 2 || m.failed != 1 {
+		t.Errorf("metrics after shutdown: %+v", m)
+	}
+}
+
+func TestWorkerPoolShutdownTimeoutCancelsJobs(t *testing.T) {
+	pool := newWorkerPool(realClock{}, poolConfig{maxWorkers: 2, queueSize: 8},
+		func(ctx context.Context, i int) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+	var failed int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for res := range pool.Results() {
+			if errors.Is(res.err, context.Canceled) {
+				failed++
+			}
+		}
+	}()
+	for i := 0; i < 6; i++ {
+		pool.Submit(context.Background(), i)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	<-done
+	if failed != 6 {
+		t.Errorf("%d jobs were cancelled, want 6", failed)
+	}
+}
+
+func TestWorkerPoolDynamicWorkers(t *testing.T) {
+	fc := newFakeClock(time.Date(2023, 3, 12, 17, 11, 57, 0, time.UTC))
+	fc.run(func() {
+		pool := newWorkerPool(fc, poolConfig{minWorkers: 1, maxWorkers: 5, queueSize: 10, idleTimeout: time.Second},
+			func(ctx context.Context, i int) (int, error) {
+				fc.Sleep(100 * time.Millisecond)
+				return i, nil
+			})
+		go func() {
+			for range pool.Results() {
+			}
+		}()
+		for i := 0; i < 10; i++ {
+			pool.Submit(context.Background(), i)
+		}
+		if m := pool.Metrics(); m.workers != 5 || m.peakWorkers != 5 {
+			t.Errorf("with 10 jobs outstanding: %d workers, peak %d; want 5", m.workers, m.peakWorkers)
+		}
+		fc.Sleep(5 * time.Second)
+		if m := pool.Metrics(); m.workers != 1 || m.completed != 10 {
+			t.Errorf("after idling: %d workers, %d completed; want 1 and 10", m.workers, m.completed)
+		}
+		pool.Shutdown(context.Background())
+	})
+}